@@ -0,0 +1,176 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 认证后端抽象相关功能
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// authBackend 认证后端名称，对应配置项auth.backend
+var authBackend string
+
+// defaultAuthBackend 默认的认证后端
+const defaultAuthBackend = "ruijie"
+
+// LandingPage 访问重定向URL得到的页面
+type LandingPage struct {
+	URL      string // 跟随跳转后的最终URL
+	Body     string // 页面内容
+	Location string // 重定向URL返回HTTP跳转时最后一个带查询参数的跳转地址，没有时为最终URL
+	Direct   bool   // 跳转地址由配置构建，未访问重定向URL
+}
+
+// Portal 认证后端从重定向页面识别出的门户
+type Portal struct {
+	LoginURL    string       // 登录URL
+	QueryString string       // 查询字符串，已URL编码
+	Form        string       // 识别门户时匹配的跳转形式，可为空
+	Cookie      *http.Cookie // 门户的HTTP Cookie，为nil时由认证后端获取
+	Backend     string       // 识别出的其他认证后端，为空时使用配置的后端
+}
+
+// statusItem 在线会话的统计信息项，如在线时长、已用流量
+type statusItem struct {
+	Name  string // 显示名称
+	Value string // 数值
+}
+
+// OnlineStatus 在线会话信息
+type OnlineStatus struct {
+	Account string       // 认证账号
+	Name    string       // 用户姓名
+	IP      string       // IP地址
+	MAC     string       // MAC地址
+	Service string       // 服务类型
+	Items   []statusItem // 统计信息
+	Query   *PortalQuery // 登录时的门户查询参数，可为nil
+}
+
+// Authenticator 认证后端，封装某一类门户的认证协议
+type Authenticator interface {
+	// Name 返回后端名称，与配置项auth.backend的取值一致
+	Name() string
+	// Detect 从重定向页面识别门户，无法识别时返回ErrRedirectNotFound
+	Detect(page *LandingPage) (*Portal, error)
+	// Login 在门户上认证，成功后应调用setSession记录会话
+	Login(portal *Portal) (*LoginResult, error)
+	// Logout 下线会话，返回门户的提示信息
+	Logout(session *portalSession) (string, error)
+	// Status 查询在线会话
+	Status(session *portalSession) (*OnlineStatus, error)
+}
+
+// Keepaliver 需要定时保活的认证后端
+type Keepaliver interface {
+	// Keepalive 发送一次保活请求，会话失效时返回errSessionLost
+	Keepalive(session *portalSession) error
+}
+
+// Discoverer 不依赖重定向页面的认证后端，如802.1X
+type Discoverer interface {
+	// Discover 直接返回认证所需的门户信息，跳过重定向页面
+	Discover() (*Portal, error)
+}
+
+// errSessionLost 保活检测到会话已失效
+var errSessionLost = errors.New("session lost")
+
+// authenticators 已注册的认证后端
+var authenticators = map[string]func() Authenticator{}
+
+// registerAuthenticator 注册认证后端，在各后端文件的init中调用
+// 参数:
+//   - name: 后端名称
+//   - factory: 创建后端实例的函数
+func registerAuthenticator(name string, factory func() Authenticator) {
+	authenticators[name] = factory
+}
+
+// authenticatorNames 返回已注册的认证后端名称，按字母排序
+func authenticatorNames() []string {
+	names := make([]string, 0, len(authenticators))
+	for name := range authenticators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getAuthenticator 根据名称获取认证后端
+// 参数: name - 后端名称，为空时使用配置项auth.backend
+// 返回值: 认证后端和可能的错误
+func getAuthenticator(name string) (Authenticator, error) {
+	if name == "" {
+		name = authBackend
+	}
+	if name == "" {
+		name = defaultAuthBackend
+	}
+	factory, ok := authenticators[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown auth backend %q, available: %s", name, strings.Join(authenticatorNames(), ", "))
+	}
+	return factory(), nil
+}
+
+// portalAuthenticator 获取认证门户使用的后端
+// 识别门户时发现其他后端的门户（如锐捷后端识别出Dr.COM页面）时使用该后端
+// 参数:
+//   - auth: 配置的认证后端
+//   - portal: 门户
+// 返回值: 认证后端和可能的错误
+func portalAuthenticator(auth Authenticator, portal *Portal) (Authenticator, error) {
+	if portal.Backend == "" || portal.Backend == auth.Name() {
+		return auth, nil
+	}
+	return getAuthenticator(portal.Backend)
+}
+
+// DetectPortal 检测网络连接状态，未连接时从重定向页面识别门户
+// 参数: auth - 认证后端
+// 返回值: 门户、网络连接状态和可能的错误
+func DetectPortal(auth Authenticator) (*Portal, bool, error) {
+	// 检测网络连接状态
+	connected, err := isConnected()
+	if err != nil || connected {
+		return nil, connected, err
+	}
+	portal, err := discoverPortal(auth)
+	return portal, false, err
+}
+
+// discoverPortal 不检测网络连接状态，直接从重定向页面识别门户
+// 参数: auth - 认证后端
+// 返回值: 门户和可能的错误
+func discoverPortal(auth Authenticator) (*Portal, error) {
+	// 后端不依赖重定向页面时直接获取门户信息
+	if d, ok := auth.(Discoverer); ok {
+		return d.Discover()
+	}
+	// 获取重定向页面，配置了门户地址时直接构建门户跳转地址
+	var page *LandingPage
+	var err error
+	if portalURL != "" {
+		page, err = directLandingPage()
+	} else {
+		page, err = fetchLandingPage()
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 由认证后端识别门户
+	return auth.Detect(page)
+}
+
+// sessionAuthenticator 获取会话对应的认证后端
+// 会话未记录后端时使用配置的后端
+func sessionAuthenticator(s *portalSession) (Authenticator, error) {
+	return getAuthenticator(s.Backend)
+}
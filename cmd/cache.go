@@ -0,0 +1,106 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 门户缓存相关功能，断网后跳过重定向页面直接认证
+package cmd
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// portalCacheTTL 门户缓存的有效期，0表示不使用缓存
+var portalCacheTTL time.Duration
+
+// portalCookieTTL 缓存的门户Cookie的有效期
+// 门户的会话远短于门户缓存的有效期，过期后重新获取Cookie
+const portalCookieTTL = 10 * time.Minute
+
+// portalCache 上次认证成功的门户，与会话记录不同，下线后仍然保留
+type portalCache struct {
+	Backend     string    `json:"backend"`               // 认证后端
+	LoginURL    string    `json:"loginUrl"`              // 登录URL
+	QueryString string    `json:"queryString"`           // 查询字符串
+	CookieName  string    `json:"cookieName,omitempty"`  // 门户Cookie名称
+	CookieValue string    `json:"cookieValue,omitempty"` // 门户Cookie值
+	CookieExp   time.Time `json:"cookieExp,omitzero"`    // 门户Cookie的过期时间
+	Detected    string    `json:"detected,omitempty"`    // 识别出的其他认证后端
+	Time        time.Time `json:"time"`                  // 认证成功的时间
+}
+
+// getPortalCacheFile 获取门户缓存文件路径
+func getPortalCacheFile() string {
+	return filepath.Join(homeDir, "HustWebAuth.portal")
+}
+
+// loadPortalCache 读取认证后端的门户缓存
+// 参数: backend - 认证后端名称
+// 返回值: 缓存的门户，未启用缓存、没有缓存、后端不一致或已过期时返回nil
+func loadPortalCache(backend string) *Portal {
+	if portalCacheTTL <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(getPortalCacheFile())
+	if err != nil {
+		return nil
+	}
+	c := &portalCache{}
+	if err = json.Unmarshal(data, c); err != nil || c.Backend != backend || c.LoginURL == "" {
+		return nil
+	}
+	if time.Since(c.Time) > portalCacheTTL {
+		return nil
+	}
+	portal := &Portal{LoginURL: c.LoginURL, QueryString: c.QueryString, Form: redirectFormCache, Backend: c.Detected}
+	// Cookie过期后不再复用，由认证后端重新获取
+	if c.CookieName != "" && time.Now().Before(c.CookieExp) {
+		portal.Cookie = &http.Cookie{Name: c.CookieName, Value: c.CookieValue, Expires: c.CookieExp}
+	}
+	return portal
+}
+
+// savePortalCache 写入认证成功的门户
+// 写入失败时仅记录日志，不影响认证流程
+// 参数:
+//   - backend: 认证后端名称
+//   - portal: 认证成功的门户
+func savePortalCache(backend string, portal *Portal) {
+	if portalCacheTTL <= 0 {
+		return
+	}
+	c := &portalCache{
+		Backend:     backend,
+		LoginURL:    portal.LoginURL,
+		QueryString: portal.QueryString,
+		Detected:    portal.Backend,
+		Time:        time.Now(),
+	}
+	if portal.Cookie != nil {
+		c.CookieName, c.CookieValue = portal.Cookie.Name, portal.Cookie.Value
+		// 复用的Cookie保留原过期时间，新获取的Cookie从现在起计算有效期
+		c.CookieExp = time.Now().Add(portalCookieTTL)
+		if exp := portal.Cookie.Expires; !exp.IsZero() && exp.Before(c.CookieExp) {
+			c.CookieExp = exp
+		}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		log.Println("Save portal cache failed, Err: ", err)
+		return
+	}
+	if err = os.WriteFile(getPortalCacheFile(), data, 0600); err != nil {
+		log.Println("Save portal cache failed, Err: ", err)
+	}
+}
+
+// clearPortalCache 删除门户缓存
+func clearPortalCache() {
+	if err := os.Remove(getPortalCacheFile()); err != nil && !os.IsNotExist(err) {
+		log.Println("Remove portal cache failed, Err: ", err)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 验证码识别相关功能
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	urlutil "net/url"
+	"os"
+	"strings"
+)
+
+// 验证码相关变量
+var (
+	captchaSolver      string // 外部验证码识别命令，图片路径作为最后一个参数传入
+	captchaInteractive bool   // 是否允许在终端中手动输入验证码，仅login命令启用
+)
+
+// downloadCaptcha 使用认证Cookie下载验证码图片并保存到临时文件
+// 参数:
+//   - loginUrl: 登录URL，用于解析相对的验证码地址
+//   - validCodeUrl: 验证码地址
+//   - cookie: HTTP Cookie
+// 返回值: 图片文件路径和可能的错误
+func downloadCaptcha(loginUrl string, validCodeUrl string, cookie *http.Cookie) (string, error) {
+	// 解析验证码地址，支持相对地址
+	base, err := urlutil.Parse(loginUrl)
+	if err != nil {
+		return "", err
+	}
+	ref, err := base.Parse(validCodeUrl)
+	if err != nil {
+		return "", err
+	}
+
+	// 创建GET请求，与登录使用同一Cookie
+	req, err := http.NewRequest("GET", ref.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	req.Header.Add("User-Agent", GetUserAgent())
+
+	// 发送请求
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: download captcha: %s", ErrPortalServer, resp.Status)
+	}
+
+	// 根据Content-Type确定文件扩展名
+	ext := ".jpg"
+	if exts, _ := mime.ExtensionsByType(resp.Header.Get("Content-Type")); len(exts) > 0 {
+		ext = exts[0]
+	}
+
+	// 保存到临时文件
+	f, err := os.CreateTemp("", "HustWebAuth-captcha-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err = io.Copy(f, resp.Body); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// isTerminal 判断标准输入是否为终端
+func isTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// runCaptchaSolver 调用外部命令识别验证码
+// 参数: imagePath - 验证码图片路径
+// 返回值: 识别结果和可能的错误
+func runCaptchaSolver(imagePath string) (string, error) {
+	fields := strings.Fields(captchaSolver)
+	if len(fields) == 0 {
+		return "", errors.New("captcha solver is empty")
+	}
+	args := append(fields[1:], imagePath)
+	code, out, err := RunCommand(fields[0], args...)
+	if err != nil {
+		return "", err
+	}
+	if code != 0 {
+		return "", fmt.Errorf("captcha solver exited with code %d: %s", code, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// promptCaptcha 提示用户在终端中输入验证码
+// 参数: imagePath - 验证码图片路径
+// 返回值: 用户输入的验证码和可能的错误
+func promptCaptcha(imagePath string) (string, error) {
+	log.Println("Captcha image saved to: ", imagePath)
+	fmt.Fprint(os.Stderr, "Please enter the captcha: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// solveCaptcha 下载并识别验证码
+// 优先使用外部识别命令，未配置（或仅包含空白）时在login命令中提示用户输入
+// 参数:
+//   - loginUrl: 登录URL
+//   - validCodeUrl: 验证码地址
+//   - cookie: HTTP Cookie
+// 返回值: 验证码和可能的错误
+func solveCaptcha(loginUrl string, validCodeUrl string, cookie *http.Cookie) (string, error) {
+	hasSolver := strings.TrimSpace(captchaSolver) != ""
+	if !hasSolver && !(captchaInteractive && isTerminal()) {
+		return "", fmt.Errorf("%w: please configure a captcha solver or run login in a terminal", ErrCaptchaRequired)
+	}
+
+	// 下载验证码图片
+	imagePath, err := downloadCaptcha(loginUrl, validCodeUrl, cookie)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(imagePath)
+
+	// 识别验证码
+	var code string
+	if hasSolver {
+		code, err = runCaptchaSolver(imagePath)
+	} else {
+		code, err = promptCaptcha(imagePath)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrCaptchaRequired, err)
+	}
+	if code == "" {
+		return "", fmt.Errorf("%w: empty captcha", ErrCaptchaRequired)
+	}
+	return code, nil
+}
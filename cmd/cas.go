@@ -0,0 +1,261 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// CAS统一身份认证相关功能
+package cmd
+
+import (
+	"crypto/des"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	urlutil "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// casEnable 门户是否使用CAS统一身份认证，登录URL指向CAS时自动启用
+var casEnable bool
+
+// CAS登录页面解析相关正则表达式
+var (
+	// casURLRegexp 匹配CAS统一身份认证的地址
+	casURLRegexp = regexp.MustCompile(`(?i)/(?:cas|authserver)/|[?&]service=`)
+	// casFormRegexp 匹配包含lt或execution隐藏字段的登录表单
+	casFormRegexp = regexp.MustCompile(`(?is)<form[^>]*>.*?</form>`)
+	// casActionRegexp 匹配表单提交地址
+	casActionRegexp = regexp.MustCompile(`(?i)<form[^>]*action\s*=\s*["']([^"']*)["']`)
+	// casInputRegexp 匹配表单中的input标签
+	casInputRegexp = regexp.MustCompile(`(?i)<input[^>]*>`)
+	// casAttrRegexp 匹配标签属性
+	casAttrRegexp = regexp.MustCompile(`(?i)([\w-]+)\s*=\s*["']([^"']*)["']`)
+	// casStrEncRegexp 匹配页面脚本中调用strEnc时使用的三个密钥
+	casStrEncRegexp = regexp.MustCompile(`strEnc\([^,]+,\s*['"]([^'"]*)['"]\s*,\s*['"]([^'"]*)['"]\s*,\s*['"]([^'"]*)['"]`)
+	// casErrorRegexp 匹配登录失败时页面中的错误信息
+	casErrorRegexp = regexp.MustCompile(`(?is)<[^>]*(?:id|class)\s*=\s*["'](?:errormsg|msg|errors|error_tips|login_error)["'][^>]*>(.*?)</`)
+	// casTagRegexp 匹配HTML标签，用于提取纯文本
+	casTagRegexp = regexp.MustCompile(`<[^>]*>`)
+)
+
+// casPage CAS登录页面
+type casPage struct {
+	Body   string            // 页面内容
+	Action string            // 表单提交地址
+	Fields map[string]string // 表单中的隐藏字段
+}
+
+// casPortalLogin 通过CAS登录锐捷门户，并查询登录后的在线用户信息
+// 在线用户信息包含保活间隔等字段，与账号密码登录的响应一致，查询失败时仅包含用户索引
+// 参数:
+//   - loginUrl: 登录URL
+//   - cookie: 门户的HTTP Cookie
+// 返回值: 门户响应（门户未跳转到CAS时为nil）和可能的错误
+func casPortalLogin(loginUrl string, cookie *http.Cookie) (*PortalResponse, error) {
+	userIndex, isCAS, err := casLogin(loginUrl, cookie)
+	if err != nil || !isCAS {
+		return nil, err
+	}
+	info, err := lookupOnlineUserInfo(loginUrl, userIndex)
+	if err != nil {
+		return &PortalResponse{Result: "success", UserIndex: userIndex}, nil
+	}
+	return info, nil
+}
+
+// casLogin 门户跳转到CAS统一身份认证时，通过CAS登录
+// 与GetCookie获取的门户会话共用Cookie，CAS登录成功后跟随票据跳转回门户完成认证
+// 参数:
+//   - loginUrl: 登录URL
+//   - cookie: 门户的HTTP Cookie
+// 返回值: 门户的用户索引、门户是否使用CAS和可能的错误
+func casLogin(loginUrl string, cookie *http.Cookie) (string, bool, error) {
+	client, err := newCASClient(loginUrl, cookie)
+	if err != nil {
+		return "", false, err
+	}
+
+	// 访问登录URL，门户使用CAS时会跳转到CAS登录页面
+	resp, err := client.Get(loginUrl)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	page, err := readCASPage(resp)
+	if err != nil {
+		return "", false, err
+	}
+	if page == nil {
+		return "", false, nil
+	}
+
+	// 填写并提交登录表单，CAS签发票据后跳转回门户
+	form := casLoginForm(page, account, password)
+	resp, err = client.PostForm(page.Action, form)
+	if err != nil {
+		return "", true, fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	result, err := readCASPage(resp)
+	if err != nil {
+		return "", true, err
+	}
+	// 仍停留在CAS登录页面，说明登录失败
+	if result != nil {
+		msg := "CAS authentication failed"
+		if match := casErrorRegexp.FindStringSubmatch(result.Body); match != nil {
+			if text := strings.TrimSpace(html.UnescapeString(casTagRegexp.ReplaceAllString(match[1], ""))); text != "" {
+				msg = text
+			}
+		}
+		return "", true, classifyMessage("Login fail", msg)
+	}
+
+	// 门户认证成功后通常在跳转地址中携带用户索引，否则从门户查询
+	if userIndex := resp.Request.URL.Query().Get("userIndex"); userIndex != "" {
+		return userIndex, true, nil
+	}
+	userIndex, err := getOnlineUserIndex(loginUrl)
+	if err != nil {
+		return "", true, fmt.Errorf("Login fail: CAS ticket not accepted by the portal: %w", err)
+	}
+	return userIndex, true, nil
+}
+
+// newCASClient 创建CAS登录使用的HTTP客户端
+// 与共享的HTTP客户端使用同一传输层，通过Cookie Jar跨域保存门户和CAS的会话
+// 参数:
+//   - loginUrl: 登录URL
+//   - cookie: 门户的HTTP Cookie，可为nil
+// 返回值: HTTP客户端和可能的错误
+func newCASClient(loginUrl string, cookie *http.Cookie) (*http.Client, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	if cookie != nil {
+		u, err := urlutil.Parse(loginUrl)
+		if err != nil {
+			return nil, err
+		}
+		jar.SetCookies(u, []*http.Cookie{cookie})
+	}
+	shared := getHTTPClient()
+	return &http.Client{Transport: shared.Transport, Timeout: shared.Timeout, Jar: jar}, nil
+}
+
+// readCASPage 读取响应并解析CAS登录页面
+// 参数: resp - HTTP响应，读取后关闭
+// 返回值: CAS登录页面，不是CAS登录页面时为nil，以及可能的错误
+func readCASPage(resp *http.Response) (*casPage, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%w: %s", ErrPortalServer, resp.Status)
+	}
+
+	// 查找包含lt或execution隐藏字段的表单
+	for _, form := range casFormRegexp.FindAllString(string(body), -1) {
+		fields := map[string]string{}
+		for _, input := range casInputRegexp.FindAllString(form, -1) {
+			attrs := map[string]string{}
+			for _, attr := range casAttrRegexp.FindAllStringSubmatch(input, -1) {
+				attrs[strings.ToLower(attr[1])] = html.UnescapeString(attr[2])
+			}
+			if attrs["name"] != "" && strings.EqualFold(attrs["type"], "hidden") {
+				fields[attrs["name"]] = attrs["value"]
+			}
+		}
+		_, hasLt := fields["lt"]
+		_, hasExecution := fields["execution"]
+		if !hasLt && !hasExecution {
+			continue
+		}
+
+		// 解析表单提交地址，未指定时提交到当前页面
+		action := resp.Request.URL
+		if match := casActionRegexp.FindStringSubmatch(form); match != nil && match[1] != "" {
+			if action, err = action.Parse(html.UnescapeString(match[1])); err != nil {
+				return nil, err
+			}
+		}
+		return &casPage{Body: string(body), Action: action.String(), Fields: fields}, nil
+	}
+	return nil, nil
+}
+
+// casLoginForm 构建CAS登录表单
+// 页面包含rsa字段时使用页面脚本的strEnc加密账号密码，否则直接提交账号密码
+// 参数:
+//   - page: CAS登录页面
+//   - username: 账号
+//   - password: 密码
+// 返回值: 表单数据
+func casLoginForm(page *casPage, username string, password string) urlutil.Values {
+	form := urlutil.Values{}
+	for k, v := range page.Fields {
+		form.Set(k, v)
+	}
+	if form.Get("_eventId") == "" {
+		form.Set("_eventId", "submit")
+	}
+	if _, ok := page.Fields["rsa"]; ok {
+		keys := []string{"1", "2", "3"}
+		if match := casStrEncRegexp.FindStringSubmatch(page.Body); match != nil {
+			keys = match[1:]
+		}
+		form.Set("rsa", strEnc(username+password+page.Fields["lt"], keys[0], keys[1], keys[2]))
+		form.Set("ul", strconv.Itoa(len(utf16.Encode([]rune(username)))))
+		form.Set("pl", strconv.Itoa(len(utf16.Encode([]rune(password)))))
+	} else {
+		form.Set("username", username)
+		form.Set("password", password)
+	}
+	return form
+}
+
+// strEnc 实现CAS登录页面des.js中的strEnc加密
+// 数据和密钥按UTF-16每4个字符分为一组（不足补0），每组数据依次使用三个密钥的各组做DES加密
+// 参数:
+//   - data: 明文
+//   - firstKey: 第一个密钥
+//   - secondKey: 第二个密钥
+//   - thirdKey: 第三个密钥
+// 返回值: 大写十六进制密文
+func strEnc(data string, firstKey string, secondKey string, thirdKey string) string {
+	var keys [][]byte
+	for _, key := range []string{firstKey, secondKey, thirdKey} {
+		keys = append(keys, strEncBlocks(key)...)
+	}
+	var res strings.Builder
+	for _, block := range strEncBlocks(data) {
+		for _, key := range keys {
+			// DES密钥长度固定为8字节，不会出错
+			c, _ := des.NewCipher(key)
+			c.Encrypt(block, block)
+		}
+		res.WriteString(strings.ToUpper(hex.EncodeToString(block)))
+	}
+	return res.String()
+}
+
+// strEncBlocks 将字符串按UTF-16大端序每4个字符分为8字节的一组，不足补0
+func strEncBlocks(s string) [][]byte {
+	units := utf16.Encode([]rune(s))
+	var blocks [][]byte
+	for i := 0; i < len(units); i += 4 {
+		block := make([]byte, 8)
+		for j := 0; j < 4 && i+j < len(units); j++ {
+			block[2*j] = byte(units[i+j] >> 8)
+			block[2*j+1] = byte(units[i+j])
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import "testing"
+
+// 预期结果按CAS登录页面des.js的strEnc分组规则（UTF-16大端序每4个字符一组，不足补0），
+// 使用OpenSSL的DES-ECB依次以各密钥分组加密计算
+func TestStrEnc(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		first  string
+		second string
+		third  string
+		want   string
+	}{
+		{
+			name:   "username, password and lt",
+			data:   "M202012345secretLT-42-abcdefXYZ-cas",
+			first:  "1",
+			second: "2",
+			third:  "3",
+			want:   "030DE3EBCD2E3B4CF3E99BF4EEBF01FA04B62AC886189F3CE14B7FCD5F126F2C460D2143824AA9F6461EA592706E6B33BA90266E08864C0EF25801254695AC50A1479D1A2FB03BB0",
+		},
+		{
+			name:   "single character",
+			data:   "u",
+			first:  "1",
+			second: "2",
+			third:  "3",
+			want:   "07F0AFD371CF7017",
+		},
+		{
+			name:   "non-ascii data and multi-block keys",
+			data:   "用户密码",
+			first:  "key1key2",
+			second: "k",
+			third:  "longkey-longkey",
+			want:   "B01F716EDF037F59",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strEnc(tt.data, tt.first, tt.second, tt.third); got != tt.want {
+				t.Errorf("strEnc() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// 登录页面指定的密钥优先于默认密钥
+func TestCASLoginForm(t *testing.T) {
+	page := &casPage{
+		Body:   `<script>$("#rsa").val(strEnc(u+p+lt , 'key1key2' , 'k' , 'longkey-longkey'));</script>`,
+		Fields: map[string]string{"lt": "LT-42-abcdefXYZ-cas", "execution": "e1s1", "rsa": ""},
+	}
+	form := casLoginForm(page, "M202012345", "secret")
+	want := map[string]string{
+		"lt":        "LT-42-abcdefXYZ-cas",
+		"execution": "e1s1",
+		"_eventId":  "submit",
+		"rsa":       "66E62D21B20C1D68B68762EB7B475C0105E7A069D8807FF31A46761A3DED8D772D08B5FB8F3736B2A84E06CFA5405A3E653F4BCB941FE6ED8FE51513A1F69514742123C508A2155A",
+		"ul":        "10",
+		"pl":        "6",
+	}
+	for k, v := range want {
+		if got := form.Get(k); got != v {
+			t.Errorf("form[%s] = %q, want %q", k, got, v)
+		}
+	}
+	if form.Has("password") {
+		t.Error("plain password must not be submitted with the rsa field")
+	}
+}
@@ -0,0 +1,572 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 网络连接检测相关功能
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	urlutil "net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ping "github.com/prometheus-community/pro-bing"
+)
+
+// 网络连接检测相关变量
+var (
+	detectSpecs   []string      // 检测器列表，如icmp、http:URL、tcp:host:port、dns:name
+	detectRule    string        // 检测结果的组合规则，选项: any、all、quorum
+	detectQuorum  int           // quorum规则下需要判定已连接的检测器数量，0表示过半
+	detectTimeout time.Duration // HTTP、TCP和DNS检测的超时时间
+)
+
+// 检测结果的组合规则
+const (
+	detectRuleAny    = "any"    // 任一检测器判定已连接
+	detectRuleAll    = "all"    // 全部检测器判定已连接
+	detectRuleQuorum = "quorum" // 至少detectQuorum个检测器判定已连接
+)
+
+// defaultHTTPDetectStatus HTTP检测默认期望的状态码，与generate_204类地址一致
+const defaultHTTPDetectStatus = http.StatusNoContent
+
+// Detector 网络连接检测器
+type Detector interface {
+	// Name 返回检测器名称，用于日志
+	Name() string
+	// Detect 检测网络是否已连接
+	// 网络不通时返回false，仅在无法检测（如配置错误、权限不足）时返回错误
+	Detect() (bool, error)
+}
+
+// detectReporter 可输出检测详情的检测器
+type detectReporter interface {
+	// Items 返回最近一次检测的详情，用于状态输出
+	Items() []statusItem
+}
+
+// detectorFactories 已注册的检测器类型
+var detectorFactories = map[string]func(target string) (Detector, error){}
+
+// registerDetector 注册检测器类型
+// 参数:
+//   - name: 检测器类型，即检测器描述中冒号前的部分
+//   - factory: 根据冒号后的目标创建检测器的函数
+func registerDetector(name string, factory func(target string) (Detector, error)) {
+	detectorFactories[name] = factory
+}
+
+// init 注册内置的检测器类型
+func init() {
+	registerDetector("icmp", newICMPDetector)
+	registerDetector("http", newHTTPDetector)
+	registerDetector("tcp", newTCPDetector)
+	registerDetector("dns", newDNSDetector)
+}
+
+// newDetector 根据描述创建检测器
+// 参数: spec - 检测器描述，格式为type[:target]
+// 返回值: 检测器和可能的错误
+func newDetector(spec string) (Detector, error) {
+	typ, target, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	factory, ok := detectorFactories[strings.ToLower(typ)]
+	if !ok {
+		names := make([]string, 0, len(detectorFactories))
+		for name := range detectorFactories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown detector %q, available: %s", spec, strings.Join(names, ", "))
+	}
+	return factory(target)
+}
+
+// detectResult 一个检测器的检测结果
+type detectResult struct {
+	Detector  Detector // 检测器
+	Connected bool     // 是否判定已连接
+	Err       error    // 无法检测时的错误
+}
+
+// isConnected 使用配置的检测器检测网络是否已连接
+// 各检测器并发执行，结果按detectRule组合，判定未连接时记录检测详情
+// 返回值: 网络连接状态和可能的错误
+func isConnected() (bool, error) {
+	results, err := runDetectors()
+	if err != nil {
+		return false, err
+	}
+	connected, err := combineDetectResults(results, detectRule, detectQuorum)
+	if err == nil && !connected {
+		// 记录检测详情，便于区分真实断网和个别目标故障
+		for _, r := range results {
+			if reporter, ok := r.Detector.(detectReporter); ok && r.Err == nil {
+				for _, item := range reporter.Items() {
+					log.Println(item.Name+": ", item.Value)
+				}
+			}
+		}
+	}
+	return connected, err
+}
+
+// runDetectors 并发执行配置的检测器
+// 返回值: 按配置顺序排列的检测结果和可能的错误
+func runDetectors() ([]detectResult, error) {
+	specs := detectSpecs
+	if len(specs) == 0 {
+		specs = []string{"icmp"}
+	}
+	detectors := make([]Detector, 0, len(specs))
+	for _, spec := range specs {
+		d, err := newDetector(spec)
+		if err != nil {
+			return nil, err
+		}
+		detectors = append(detectors, d)
+	}
+
+	results := make([]detectResult, len(detectors))
+	var wg sync.WaitGroup
+	for i, d := range detectors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			connected, err := d.Detect()
+			results[i] = detectResult{Detector: d, Connected: connected, Err: err}
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// combineDetectResults 按规则组合检测结果
+// 无法检测的检测器视为未连接，全部检测器都无法检测时返回第一个错误
+// 参数:
+//   - results: 检测结果
+//   - rule: 组合规则
+//   - quorum: quorum规则下需要判定已连接的检测器数量，0表示过半
+// 返回值: 网络连接状态和可能的错误
+func combineDetectResults(results []detectResult, rule string, quorum int) (bool, error) {
+	connected, failed := 0, 0
+	var firstErr error
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", r.Detector.Name(), r.Err)
+			}
+		} else if r.Connected {
+			connected++
+		}
+	}
+	if failed == len(results) && firstErr != nil {
+		return false, firstErr
+	}
+
+	switch strings.ToLower(rule) {
+	case "", detectRuleAny:
+		return connected > 0, nil
+	case detectRuleAll:
+		return connected == len(results), nil
+	case detectRuleQuorum:
+		if quorum <= 0 {
+			quorum = len(results)/2 + 1
+		}
+		// 检测器数量少于quorum时要求全部已连接
+		quorum = min(quorum, len(results))
+		return connected >= quorum, nil
+	}
+	return false, fmt.Errorf("unknown detect rule %q, available: any, all, quorum", rule)
+}
+
+// printDetectResults 执行配置的检测器并输出各检测器的结果
+func printDetectResults() {
+	results, err := runDetectors()
+	if err != nil {
+		log.Println("Detect failed, Err: ", err)
+		return
+	}
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			log.Println(r.Detector.Name()+": ", "error: "+r.Err.Error())
+		case r.Connected:
+			log.Println(r.Detector.Name()+": ", "connected")
+		default:
+			log.Println(r.Detector.Name()+": ", "disconnected")
+		}
+		if reporter, ok := r.Detector.(detectReporter); ok && r.Err == nil {
+			for _, item := range reporter.Items() {
+				log.Println(item.Name+": ", item.Value)
+			}
+		}
+	}
+}
+
+// icmpDetector 通过ping检测网络连接
+// 并发Ping多个目标，可达的目标数量达到pingQuorum时判定已连接
+type icmpDetector struct {
+	targets  []string    // ping的目标IP地址
+	quorum   int         // 判定已连接需要可达的目标数量
+	stats    []*pingStat // 最近一次检测的各目标统计
+	fallback Detector    // 无权发送ICMP时改用的HTTP检测器，未回退时为nil
+}
+
+// pingStat 一个Ping目标的统计结果
+type pingStat struct {
+	IP    string           // 目标IP地址
+	Stats *ping.Statistics // Ping统计，无法Ping时为nil
+	Err   error            // 无法Ping时的错误
+}
+
+// String 返回统计结果的文本，包括收发包数、丢包率和平均RTT
+func (s *pingStat) String() string {
+	if s.Err != nil {
+		return "error: " + s.Err.Error()
+	}
+	st := s.Stats
+	text := fmt.Sprintf("%d/%d received, %.0f%% loss", st.PacketsRecv, st.PacketsSent, st.PacketLoss)
+	if st.PacketsRecv > 0 {
+		text += fmt.Sprintf(", rtt min/avg/max %v/%v/%v", st.MinRtt, st.AvgRtt, st.MaxRtt)
+	}
+	return text
+}
+
+// reachable 目标是否可达，丢包率小于100%时视为可达
+func (s *pingStat) reachable() bool {
+	return s.Err == nil && s.Stats.PacketLoss < 100.0
+}
+
+// newICMPDetector 创建ICMP检测器
+// 参数: target - ping的目标IP地址，为空时使用pingIPs
+func newICMPDetector(target string) (Detector, error) {
+	targets := pingIPs
+	if target != "" {
+		targets = []string{target}
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("icmp detector requires at least one ping target")
+	}
+	return &icmpDetector{targets: targets, quorum: pingQuorum}, nil
+}
+
+// Name 返回检测器名称
+func (d *icmpDetector) Name() string {
+	return "icmp:" + strings.Join(d.targets, ",")
+}
+
+// Detect 并发Ping各目标，可达的目标数量达到quorum时判定已连接
+// 无权发送ICMP时改用HTTP请求pingFallbackURL检测，门户会拦截HTTP请求，不会误判已连接
+// 全部目标都无法Ping时返回第一个错误
+func (d *icmpDetector) Detect() (bool, error) {
+	mode := currentPingMode()
+	if mode == pingModeHTTP {
+		fallback, err := newHTTPDetector(pingFallbackURL)
+		if err != nil {
+			return false, err
+		}
+		d.stats, d.fallback = nil, fallback
+		return fallback.Detect()
+	}
+	d.fallback = nil
+	d.stats = make([]*pingStat, len(d.targets))
+	var wg sync.WaitGroup
+	for i, ip := range d.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.stats[i] = pingTarget(ip, mode)
+		}()
+	}
+	wg.Wait()
+
+	reachable, failed := 0, 0
+	var firstErr error
+	for _, s := range d.stats {
+		if s.Err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = s.Err
+			}
+		} else if s.reachable() {
+			reachable++
+		}
+	}
+	if failed == len(d.stats) {
+		return false, firstErr
+	}
+
+	quorum := d.quorum
+	if quorum <= 0 {
+		quorum = len(d.stats)/2 + 1
+	}
+	quorum = min(quorum, len(d.stats))
+	return reachable >= quorum, nil
+}
+
+// Items 返回最近一次检测的各目标统计，用于状态输出
+func (d *icmpDetector) Items() []statusItem {
+	if reporter, ok := d.fallback.(detectReporter); ok {
+		return reporter.Items()
+	}
+	items := make([]statusItem, 0, len(d.stats))
+	for _, s := range d.stats {
+		items = append(items, statusItem{Name: "Ping " + s.IP, Value: s.String()})
+	}
+	return items
+}
+
+// pingTarget 检测一个目标
+// 参数:
+//   - ip: 目标IP地址
+//   - mode: Ping模式
+// 返回值: 目标的统计结果
+func pingTarget(ip string, mode string) *pingStat {
+	// 创建ping检测器
+	pinger, err := ping.NewPinger(ip)
+	if err != nil {
+		return &pingStat{IP: ip, Err: err}
+	}
+	// 设置ping参数
+	pinger.Count = pingCount
+	pinger.Timeout = pingTimeout
+	pinger.SetPrivileged(mode == pingModePrivileged)
+	// 执行ping检测
+	if err = pinger.Run(); err != nil { // Blocks until finished.
+		return &pingStat{IP: ip, Err: err}
+	}
+	return &pingStat{IP: ip, Stats: pinger.Statistics()} // get send/receive/duplicate/rtt stats
+}
+
+// Ping模式
+const (
+	pingModePrivileged   = "privileged"   // 特权原始ICMP ping
+	pingModeUnprivileged = "unprivileged" // 非特权UDP ping
+	pingModeHTTP         = "http"         // 无权发送ICMP时改用HTTP检测
+)
+
+// 当前进程使用的Ping模式，首次检测时确定
+var (
+	pingModeOnce sync.Once
+	pingMode     string
+)
+
+// currentPingMode 返回当前进程使用的Ping模式，首次调用时确定并记录日志
+func currentPingMode() string {
+	pingModeOnce.Do(func() {
+		var reason string
+		pingMode, reason = resolvePingMode()
+		msg := "Ping mode: "
+		switch pingMode {
+		case pingModePrivileged:
+			msg += "privileged ICMP"
+		case pingModeUnprivileged:
+			msg += "unprivileged UDP"
+		case pingModeHTTP:
+			msg += "HTTP status check of " + pingFallbackURL
+		}
+		if reason != "" {
+			msg += ", " + reason
+		}
+		log.Println(msg)
+	})
+	return pingMode
+}
+
+// resolvePingMode 根据权限确定Ping模式
+// 配置了特权Ping时先尝试原始ICMP套接字，无权限时依次回退到非特权UDP ping和HTTP检测
+// 返回值: Ping模式和回退原因
+func resolvePingMode() (string, string) {
+	var denied []string
+	if pingPrivilege {
+		err := probePing(true)
+		if err == nil || !errors.Is(err, os.ErrPermission) {
+			return pingModePrivileged, ""
+		}
+		denied = append(denied, err.Error())
+	}
+
+	allowed, reason := unprivilegedPingAllowed()
+	if allowed {
+		err := probePing(false)
+		if err == nil || !errors.Is(err, os.ErrPermission) {
+			if len(denied) > 0 {
+				return pingModeUnprivileged, "privileged ICMP is not permitted: " + denied[0]
+			}
+			return pingModeUnprivileged, ""
+		}
+		reason = err.Error()
+	}
+	denied = append(denied, reason)
+
+	// 未配置回退地址时保持原有模式，由检测返回错误
+	if pingFallbackURL == "" {
+		mode := pingModeUnprivileged
+		if pingPrivilege {
+			mode = pingModePrivileged
+		}
+		return mode, "ICMP is not permitted and the HTTP fallback is disabled: " + strings.Join(denied, "; ")
+	}
+	return pingModeHTTP, "ICMP is not permitted: " + strings.Join(denied, "; ")
+}
+
+// probePing 向本机回环地址发送一次Ping，检查能否创建ICMP套接字
+// 参数: privileged - 是否使用特权Ping
+// 返回值: 创建套接字失败时的错误，丢包不视为错误
+func probePing(privileged bool) error {
+	pinger, err := ping.NewPinger("127.0.0.1")
+	if err != nil {
+		return err
+	}
+	pinger.Count = 1
+	pinger.Timeout = 500 * time.Millisecond
+	pinger.SetPrivileged(privileged)
+	return pinger.Run()
+}
+
+// httpDetector 通过HTTP状态码检测网络连接
+// 门户拦截时通常返回跳转或登录页面，与期望的状态码不同
+type httpDetector struct {
+	url    string // 检测地址
+	status int    // 期望的状态码
+	result string // 最近一次检测的响应状态或错误
+}
+
+// newHTTPDetector 创建HTTP检测器
+// 参数: target - 检测地址，片段部分为期望的状态码，如http://connect.rom.miui.com/generate_204#204
+func newHTTPDetector(target string) (Detector, error) {
+	u, err := urlutil.Parse(target)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid http detector url %q", target)
+	}
+	status := defaultHTTPDetectStatus
+	if u.Fragment != "" {
+		if status, err = strconv.Atoi(u.Fragment); err != nil {
+			return nil, fmt.Errorf("invalid http detector status %q", u.Fragment)
+		}
+		u.Fragment = ""
+	}
+	return &httpDetector{url: u.String(), status: status}, nil
+}
+
+// Name 返回检测器名称
+func (d *httpDetector) Name() string {
+	return "http:" + d.url
+}
+
+// Detect 请求检测地址，不跟随跳转，状态码与期望一致时判定已连接
+func (d *httpDetector) Detect() (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, d.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", GetUserAgent())
+	client := &http.Client{
+		Transport: getHTTPClient().Transport,
+		Timeout:   detectTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		d.result = "error: " + err.Error()
+		return false, nil
+	}
+	resp.Body.Close()
+	d.result = resp.Status
+	return resp.StatusCode == d.status, nil
+}
+
+// Items 返回最近一次检测的响应状态，用于状态输出
+func (d *httpDetector) Items() []statusItem {
+	return []statusItem{{Name: "HTTP " + d.url, Value: d.result}}
+}
+
+// tcpDetector 通过TCP连接检测网络连接
+type tcpDetector struct {
+	address string // 目标地址，host:port
+}
+
+// newTCPDetector 创建TCP检测器
+// 参数: target - 目标地址，如202.114.0.131:80
+func newTCPDetector(target string) (Detector, error) {
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		return nil, fmt.Errorf("invalid tcp detector address %q: %w", target, err)
+	}
+	return &tcpDetector{address: target}, nil
+}
+
+// Name 返回检测器名称
+func (d *tcpDetector) Name() string {
+	return "tcp:" + d.address
+}
+
+// Detect 连接目标地址，连接成功时判定已连接
+func (d *tcpDetector) Detect() (bool, error) {
+	conn, err := net.DialTimeout("tcp", d.address, detectTimeout)
+	if err != nil {
+		return false, nil
+	}
+	conn.Close()
+	return true, nil
+}
+
+// dnsDetector 通过域名解析检测网络连接
+type dnsDetector struct {
+	name   string // 解析的域名
+	server string // DNS服务器地址，为空时使用系统配置
+}
+
+// newDNSDetector 创建DNS检测器
+// 参数: target - 解析的域名，可用@指定DNS服务器，如www.baidu.com@114.114.114.114
+func newDNSDetector(target string) (Detector, error) {
+	name, server, _ := strings.Cut(target, "@")
+	if name == "" {
+		return nil, errors.New("dns detector requires a name to resolve")
+	}
+	if server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+	}
+	return &dnsDetector{name: name, server: server}, nil
+}
+
+// Name 返回检测器名称
+func (d *dnsDetector) Name() string {
+	if d.server != "" {
+		return "dns:" + d.name + "@" + d.server
+	}
+	return "dns:" + d.name
+}
+
+// Detect 解析域名，解析成功时判定已连接
+func (d *dnsDetector) Detect() (bool, error) {
+	resolver := net.DefaultResolver
+	if d.server != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, d.server)
+			},
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), detectTimeout)
+	defer cancel()
+	addrs, err := resolver.LookupHost(ctx, d.name)
+	return err == nil && len(addrs) > 0, nil
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 直连认证相关功能，不依赖重定向URL构建门户跳转地址
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	urlutil "net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// 直连认证相关变量
+var (
+	portalURL       string // 门户登录页面地址，设置后不访问重定向URL
+	portalInterface string // 获取用户IP和MAC地址的网络接口，为空时使用访问门户的网络接口
+)
+
+// directLandingPage 根据配置构建门户跳转地址，用于重定向URL不被拦截的网络
+// 查询参数的优先级依次为配置项portal.params、自动检测的用户IP和MAC地址、
+// 门户地址中的查询参数、上次登录同一门户时的查询参数
+// 返回值: 以跳转地址作为HTTP跳转的重定向页面和可能的错误
+func directLandingPage() (*LandingPage, error) {
+	u, err := urlutil.Parse(portalURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid portal.url %q", portalURL)
+	}
+
+	values := urlutil.Values{}
+	// 上次登录同一门户时的查询参数，通常包含wlanacname、nasip等不便检测的参数
+	if s, err := getSession(); err == nil {
+		if last, err := urlutil.Parse(s.LoginURL); err == nil && last.Host == u.Host {
+			mergeQueryValues(values, s.Query().Values)
+		}
+	}
+	mergeQueryValues(values, u.Query())
+
+	// 自动检测用户IP和MAC地址
+	ip, mac, err := localPortalAddr(u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	values.Set("wlanuserip", ip.String())
+	if len(mac) > 0 {
+		if m, err := normalizeMAC(mac.String()); err == nil {
+			values.Set("mac", m)
+		}
+	}
+
+	for k, v := range viper.GetStringMapString("portal.params") {
+		values.Set(k, v)
+	}
+
+	u.RawQuery = encodeQueryValues(values)
+	target := u.String()
+	return &LandingPage{URL: target, Location: target, Direct: true}, nil
+}
+
+// mergeQueryValues 将查询参数合并到values，覆盖同名参数，忽略空值
+func mergeQueryValues(values urlutil.Values, from urlutil.Values) {
+	for k := range from {
+		if v := from.Get(k); v != "" {
+			values.Set(k, v)
+		}
+	}
+}
+
+// encodeQueryValues 编码查询参数，常用参数按锐捷门户的顺序在前，其余参数按名称排序
+func encodeQueryValues(values urlutil.Values) string {
+	order := []string{"wlanuserip", "wlanacname", "ssid", "nasip", "mac"}
+	rest := urlutil.Values{}
+	for k, v := range values {
+		rest[k] = v
+	}
+	keys := make([]string, 0, len(rest))
+	for _, k := range order {
+		if _, ok := rest[k]; ok {
+			keys = append(keys, k)
+			delete(rest, k)
+		}
+	}
+	others := make([]string, 0, len(rest))
+	for k := range rest {
+		others = append(others, k)
+	}
+	sort.Strings(others)
+	keys = append(keys, others...)
+
+	var buf strings.Builder
+	for _, k := range keys {
+		if buf.Len() > 0 {
+			buf.WriteByte('&')
+		}
+		buf.WriteString(urlutil.QueryEscape(k))
+		buf.WriteByte('=')
+		buf.WriteString(urlutil.QueryEscape(values.Get(k)))
+	}
+	return buf.String()
+}
+
+// localPortalAddr 获取访问门户使用的本机IPv4地址和MAC地址
+// 配置了portal.interface时使用该网络接口，否则根据路由选择访问门户的网络接口
+// 参数: host - 门户主机名或IP地址
+// 返回值: 本机IP地址、MAC地址（无法获取时为nil）和可能的错误
+func localPortalAddr(host string) (net.IP, net.HardwareAddr, error) {
+	if portalInterface != "" {
+		ifi, err := net.InterfaceByName(portalInterface)
+		if err != nil {
+			return nil, nil, err
+		}
+		ip, _ := interfaceIPv4(ifi)
+		if ip == nil {
+			return nil, nil, errors.New("interface " + portalInterface + " has no IPv4 address")
+		}
+		return ip, ifi.HardwareAddr, nil
+	}
+
+	// UDP连接不发送数据，仅用于由系统选择源地址
+	conn, err := net.Dial("udp4", net.JoinHostPort(host, "80"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	ip := conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ip, nil, nil
+	}
+	for _, ifi := range ifaces {
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+				return ip, ifi.HardwareAddr, nil
+			}
+		}
+	}
+	return ip, nil, nil
+}
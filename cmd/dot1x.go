@@ -0,0 +1,559 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 锐捷802.1X有线认证后端
+package cmd
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// 802.1X认证相关变量
+var (
+	dot1xInterface string // 认证使用的有线网络接口
+	dot1xMulticast string // EAPOL-Start的目的地址，选项: standard、ruijie
+)
+
+// EAPOL和EAP协议常量
+const (
+	etherTypeEAPOL = 0x888e // EAPOL以太网类型
+
+	eapolTypePacket     = 0x00 // EAP数据包
+	eapolTypeStart      = 0x01 // EAPOL-Start
+	eapolTypeLogoff     = 0x02 // EAPOL-Logoff
+	eapolTypeRuijieEcho = 0xbf // 锐捷私有的心跳包
+
+	eapCodeRequest  = 1 // EAP请求
+	eapCodeResponse = 2 // EAP应答
+	eapCodeSuccess  = 3 // 认证成功
+	eapCodeFailure  = 4 // 认证失败
+
+	eapTypeIdentity = 1 // 身份请求
+	eapTypeMD5      = 4 // MD5-Challenge
+)
+
+// 802.1X认证时序参数
+const (
+	dot1xTimeout      = 5 * time.Second  // 等待认证服务器响应的时间
+	dot1xStartRetry   = 3                // EAPOL-Start重试次数
+	dot1xEchoInterval = 30 * time.Second // 锐捷心跳间隔
+	dot1xFrameMinSize = 60               // 以太网最小帧长度（不含FCS）
+	dot1xClientVer    = "RG-SU For Linux V1.0"
+)
+
+// EAPOL-Start的目的组播地址
+var (
+	dot1xStandardAddr = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x03}
+	dot1xRuijieAddr   = net.HardwareAddr{0x01, 0xd0, 0xf8, 0x00, 0x00, 0x03}
+)
+
+// errEAPOLTimeout 等待EAPOL帧超时
+var errEAPOLTimeout = errors.New("eapol receive timeout")
+
+// eapolConn 收发EAPOL帧的原始套接字，由平台相关文件实现
+type eapolConn interface {
+	// MAC 返回网络接口的MAC地址
+	MAC() net.HardwareAddr
+	// Send 发送以太网帧
+	Send(frame []byte) error
+	// Receive 接收一个EAPOL以太网帧，超时返回errEAPOLTimeout
+	Receive(timeout time.Duration) ([]byte, error)
+	// Close 关闭套接字
+	Close() error
+}
+
+// eapMessage 解析后的EAP数据包
+type eapMessage struct {
+	Src  net.HardwareAddr // 发送方MAC地址
+	Code byte             // EAP代码
+	ID   byte             // EAP标识
+	Type byte             // EAP类型，仅请求和应答有效
+	Data []byte           // EAP类型数据
+}
+
+// dot1xSupplicant 802.1X认证客户端
+// 认证成功后由后台协程应答认证服务器的重认证请求，并检测下线通知
+type dot1xSupplicant struct {
+	conn     eapolConn        // 原始套接字
+	server   net.HardwareAddr // 认证服务器MAC地址，收到请求前为组播地址
+	trailer  []byte           // 锐捷私有字段
+	username string           // 认证账号
+	password string           // 认证密码
+
+	mu      sync.Mutex // 保护认证服务器地址和心跳状态
+	echoKey uint32     // 锐捷心跳密钥，由认证成功报文下发
+	echoNo  uint32     // 锐捷心跳序号
+	echo    bool       // 是否需要发送锐捷心跳
+
+	lost chan struct{}  // 认证服务器通知下线时关闭
+	done chan struct{}  // 停止后台协程时关闭
+	wg   sync.WaitGroup // 等待后台协程退出
+}
+
+// 当前的802.1X认证客户端，认证成功后更新，下线后清空
+var (
+	currentSupplicant *dot1xSupplicant
+	supplicantMutex   sync.Mutex
+)
+
+// dot1xAuthenticator 锐捷802.1X认证后端
+type dot1xAuthenticator struct{}
+
+// init 注册802.1X认证后端
+func init() {
+	registerAuthenticator("dot1x", func() Authenticator { return &dot1xAuthenticator{} })
+}
+
+// Name 返回后端名称
+func (a *dot1xAuthenticator) Name() string {
+	return "dot1x"
+}
+
+// Discover 802.1X认证不经过门户，直接使用配置的网络接口
+func (a *dot1xAuthenticator) Discover() (*Portal, error) {
+	if dot1xInterface == "" {
+		return nil, errors.New("dot1x.interface is not configured")
+	}
+	return &Portal{LoginURL: "dot1x://" + dot1xInterface}, nil
+}
+
+// Detect 802.1X认证不使用重定向页面
+func (a *dot1xAuthenticator) Detect(page *LandingPage) (*Portal, error) {
+	return a.Discover()
+}
+
+// Login 在网络接口上进行802.1X认证
+// 认证成功后保持套接字打开，以应答重认证请求和发送心跳
+func (a *dot1xAuthenticator) Login(portal *Portal) (*LoginResult, error) {
+	iface := strings.TrimPrefix(portal.LoginURL, "dot1x://")
+
+	// 先关闭之前的认证客户端，避免其在重新认证时继续应答EAP请求
+	supplicantMutex.Lock()
+	if currentSupplicant != nil {
+		currentSupplicant.close()
+		currentSupplicant = nil
+	}
+	supplicantMutex.Unlock()
+
+	conn, err := openEAPOL(iface)
+	if err != nil {
+		return nil, err
+	}
+	s := &dot1xSupplicant{
+		conn:     conn,
+		server:   dot1xStartAddr(),
+		trailer:  dot1xTrailer(iface),
+		username: account,
+		password: password,
+		lost:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err = s.authenticate(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// 记录新的认证客户端，并发登录时关闭被替换的客户端
+	supplicantMutex.Lock()
+	if currentSupplicant != nil {
+		currentSupplicant.close()
+	}
+	currentSupplicant = s
+	supplicantMutex.Unlock()
+	s.wg.Add(1)
+	go s.serve()
+
+	// 记录本次会话，供下线时使用
+	setSession(&portalSession{
+		Backend:  a.Name(),
+		LoginURL: portal.LoginURL,
+		Account:  account,
+		Time:     time.Now(),
+	})
+	return &LoginResult{KeepaliveInterval: dot1xEchoInterval}, nil
+}
+
+// Logout 发送EAPOL-Logoff下线
+// 当前进程没有认证客户端时，在会话记录的网络接口上发送
+func (a *dot1xAuthenticator) Logout(session *portalSession) (string, error) {
+	supplicantMutex.Lock()
+	s := currentSupplicant
+	currentSupplicant = nil
+	supplicantMutex.Unlock()
+
+	if s != nil {
+		defer s.close()
+		return "", s.conn.Send(eapolFrame(s.serverAddr(), s.conn.MAC(), eapolTypeLogoff, nil, s.trailer))
+	}
+	iface := strings.TrimPrefix(session.LoginURL, "dot1x://")
+	if iface == "" {
+		iface = dot1xInterface
+	}
+	conn, err := openEAPOL(iface)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return "", conn.Send(eapolFrame(dot1xStartAddr(), conn.MAC(), eapolTypeLogoff, nil, dot1xTrailer(iface)))
+}
+
+// Status 返回802.1X认证的网络接口信息
+// 认证服务器不提供在线信息查询，仅在当前进程检测到下线时返回错误
+func (a *dot1xAuthenticator) Status(session *portalSession) (*OnlineStatus, error) {
+	supplicantMutex.Lock()
+	s := currentSupplicant
+	supplicantMutex.Unlock()
+	if s != nil && s.isLost() {
+		return nil, errors.New("Get online user info fail: 802.1X session lost")
+	}
+
+	iface := strings.TrimPrefix(session.LoginURL, "dot1x://")
+	status := &OnlineStatus{
+		Account: session.Account,
+		Items:   []statusItem{{Name: "接口", Value: iface}, {Name: "认证时间", Value: session.Time.Format(time.DateTime)}},
+	}
+	if ifi, err := net.InterfaceByName(iface); err == nil {
+		status.MAC = ifi.HardwareAddr.String()
+		if ip, _ := interfaceIPv4(ifi); ip != nil {
+			status.IP = ip.String()
+		}
+	}
+	return status, nil
+}
+
+// Keepalive 发送锐捷心跳，认证服务器通知下线时返回errSessionLost
+func (a *dot1xAuthenticator) Keepalive(session *portalSession) error {
+	supplicantMutex.Lock()
+	s := currentSupplicant
+	supplicantMutex.Unlock()
+	if s == nil || s.isLost() {
+		return fmt.Errorf("%w: 802.1X session lost", errSessionLost)
+	}
+	return s.sendEcho()
+}
+
+// authenticate 发送EAPOL-Start并完成认证，未收到响应时重试
+func (s *dot1xSupplicant) authenticate() error {
+	for i := 0; i < dot1xStartRetry; i++ {
+		if err := s.conn.Send(eapolFrame(dot1xStartAddr(), s.conn.MAC(), eapolTypeStart, nil, s.trailer)); err != nil {
+			return err
+		}
+		done, err := s.handshake()
+		if done {
+			return err
+		}
+		if !errors.Is(err, errEAPOLTimeout) {
+			return err
+		}
+		log.Println("No response from the 802.1X authenticator, retrying...")
+	}
+	return fmt.Errorf("%w: no response from the 802.1X authenticator", ErrNetworkUnreachable)
+}
+
+// handshake 应答认证服务器的请求，直到认证成功、失败或超时
+// 返回值: 认证是否结束和认证结果
+func (s *dot1xSupplicant) handshake() (bool, error) {
+	for {
+		frame, err := s.conn.Receive(dot1xTimeout)
+		if err != nil {
+			return false, err
+		}
+		if done, err := s.handle(frame); done {
+			return true, err
+		}
+	}
+}
+
+// handle 处理一个EAPOL帧
+// 参数: frame - 以太网帧
+// 返回值: 认证是否结束和认证结果
+func (s *dot1xSupplicant) handle(frame []byte) (bool, error) {
+	msg, ok := parseEAP(frame)
+	if !ok {
+		return false, nil
+	}
+	switch msg.Code {
+	case eapCodeRequest:
+		// 记录认证服务器地址，之后的应答使用单播
+		s.mu.Lock()
+		s.server = msg.Src
+		s.mu.Unlock()
+		switch msg.Type {
+		case eapTypeIdentity:
+			s.respond(msg.ID, eapTypeIdentity, []byte(s.username))
+		case eapTypeMD5:
+			if len(msg.Data) < 1 || len(msg.Data) < 1+int(msg.Data[0]) {
+				return false, nil
+			}
+			challenge := msg.Data[1 : 1+int(msg.Data[0])]
+			s.respond(msg.ID, eapTypeMD5, eapMD5Response(msg.ID, s.password, challenge, s.username))
+		}
+	case eapCodeSuccess:
+		s.setEchoKey(frame)
+		return true, nil
+	case eapCodeFailure:
+		text := ruijieMessage(frame)
+		if text == "" {
+			text = "802.1X authentication failed"
+		}
+		return true, classifyMessage("Login fail", text)
+	}
+	return false, nil
+}
+
+// respond 发送EAP应答
+func (s *dot1xSupplicant) respond(id byte, typ byte, data []byte) {
+	body := make([]byte, 5, 5+len(data))
+	body[0], body[1] = eapCodeResponse, id
+	binary.BigEndian.PutUint16(body[2:], uint16(5+len(data)))
+	body[4] = typ
+	body = append(body, data...)
+	if err := s.conn.Send(eapolFrame(s.serverAddr(), s.conn.MAC(), eapolTypePacket, body, s.trailer)); err != nil {
+		log.Println("Send EAP response failed, Err: ", err)
+	}
+}
+
+// serve 认证成功后应答重认证请求，认证服务器通知下线时标记会话失效
+func (s *dot1xSupplicant) serve() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		frame, err := s.conn.Receive(time.Second)
+		if err != nil {
+			continue
+		}
+		if done, err := s.handle(frame); done && err != nil {
+			log.Println("802.1X session lost: ", err)
+			close(s.lost)
+			return
+		}
+	}
+}
+
+// serverAddr 返回认证服务器MAC地址
+func (s *dot1xSupplicant) serverAddr() net.HardwareAddr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.server
+}
+
+// isLost 判断认证服务器是否已通知下线
+func (s *dot1xSupplicant) isLost() bool {
+	select {
+	case <-s.lost:
+		return true
+	default:
+		return false
+	}
+}
+
+// close 停止后台协程并关闭套接字
+func (s *dot1xSupplicant) close() {
+	close(s.done)
+	s.wg.Wait()
+	s.conn.Close()
+}
+
+// setEchoKey 从认证成功报文中获取锐捷心跳密钥
+func (s *dot1xSupplicant) setEchoKey(frame []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(frame) <= 0x1b {
+		return
+	}
+	offset := 0x1c + int(frame[0x1b]) + 0x69 + 24
+	if len(frame) < offset+4 {
+		return
+	}
+	var key [4]byte
+	for i := range key {
+		key[i] = ruijieEncode(frame[offset+i])
+	}
+	s.echoKey = binary.BigEndian.Uint32(key[:])
+	s.echoNo = 0x102b
+	s.echo = true
+}
+
+// sendEcho 发送锐捷心跳，认证成功报文未下发心跳密钥时不发送
+func (s *dot1xSupplicant) sendEcho() error {
+	s.mu.Lock()
+	if !s.echo {
+		s.mu.Unlock()
+		return nil
+	}
+	key, no, server := s.echoKey+s.echoNo, s.echoNo, s.server
+	s.echoNo++
+	s.mu.Unlock()
+
+	payload := []byte{
+		0xff, 0xff, 0x37, 0x77, 0x7f, 0x9f, 0, 0, 0, 0,
+		0xff, 0xff, 0x37, 0x77, 0x7f, 0x9f, 0, 0, 0, 0,
+		0xff, 0xff, 0x37, 0x77, 0x7f, 0x3f, 0xff, 0, 0, 0,
+	}
+	for i := 0; i < 4; i++ {
+		payload[6+i] = ruijieEncode(byte(key >> (24 - 8*i)))
+		payload[16+i] = ruijieEncode(byte(no >> (24 - 8*i)))
+	}
+	return s.conn.Send(eapolFrame(server, s.conn.MAC(), eapolTypeRuijieEcho, payload, nil))
+}
+
+// dot1xStartAddr 返回配置的EAPOL-Start目的地址
+func dot1xStartAddr() net.HardwareAddr {
+	if strings.EqualFold(dot1xMulticast, "ruijie") {
+		return dot1xRuijieAddr
+	}
+	return dot1xStandardAddr
+}
+
+// eapolFrame 构建EAPOL以太网帧
+// 参数:
+//   - dst: 目的MAC地址
+//   - src: 源MAC地址
+//   - typ: EAPOL类型
+//   - body: EAPOL数据
+//   - trailer: 附加在EAPOL数据后的锐捷私有字段
+// 返回值: 以太网帧，不足最小长度时补0
+func eapolFrame(dst net.HardwareAddr, src net.HardwareAddr, typ byte, body []byte, trailer []byte) []byte {
+	frame := make([]byte, 0, 18+len(body)+len(trailer))
+	frame = append(frame, dst...)
+	frame = append(frame, src...)
+	frame = binary.BigEndian.AppendUint16(frame, etherTypeEAPOL)
+	frame = append(frame, 0x01, typ)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(body)))
+	frame = append(frame, body...)
+	frame = append(frame, trailer...)
+	for len(frame) < dot1xFrameMinSize {
+		frame = append(frame, 0)
+	}
+	return frame
+}
+
+// parseEAP 解析EAPOL以太网帧中的EAP数据包
+// 参数: frame - 以太网帧
+// 返回值: EAP数据包，不是EAP数据包时返回false
+func parseEAP(frame []byte) (*eapMessage, bool) {
+	if len(frame) < 22 || binary.BigEndian.Uint16(frame[12:]) != etherTypeEAPOL || frame[15] != eapolTypePacket {
+		return nil, false
+	}
+	msg := &eapMessage{Src: net.HardwareAddr(frame[6:12]), Code: frame[18], ID: frame[19]}
+	length := int(binary.BigEndian.Uint16(frame[20:]))
+	if (msg.Code == eapCodeRequest || msg.Code == eapCodeResponse) && length >= 5 && len(frame) >= 18+length {
+		msg.Type = frame[22]
+		msg.Data = frame[23 : 18+length]
+	}
+	return msg, true
+}
+
+// eapMD5Response 构建MD5-Challenge应答的类型数据
+// 应答值为MD5(标识 + 密码 + 挑战值)，格式为长度、应答值、账号
+// 参数:
+//   - id: EAP标识
+//   - password: 认证密码
+//   - challenge: 认证服务器下发的挑战值
+//   - username: 认证账号
+// 返回值: 类型数据
+func eapMD5Response(id byte, password string, challenge []byte, username string) []byte {
+	sum := md5.Sum(append(append([]byte{id}, password...), challenge...))
+	data := make([]byte, 0, 1+len(sum)+len(username))
+	data = append(data, byte(len(sum)))
+	data = append(data, sum[:]...)
+	return append(data, username...)
+}
+
+// ruijieEncode 锐捷私有字段的编码：将字节的8位颠倒后取反
+func ruijieEncode(b byte) byte {
+	return ^bits.Reverse8(b)
+}
+
+// ruijieMessage 读取锐捷认证失败报文中的提示信息
+// 提示信息使用GBK编码，长度位于0x1b，内容从0x1c开始
+func ruijieMessage(frame []byte) string {
+	if len(frame) <= 0x1b {
+		return ""
+	}
+	end := 0x1c + int(frame[0x1b])
+	if end > len(frame) {
+		return ""
+	}
+	text, err := simplifiedchinese.GBK.NewDecoder().Bytes(frame[0x1c:end])
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(text), "\x00")
+}
+
+// dot1xTrailer 构建锐捷私有字段
+// 包含经编码的IP地址、子网掩码、网关、DNS和客户端版本，不包含V3版本客户端的校验值
+// 参数: iface - 网络接口名称
+// 返回值: 私有字段
+func dot1xTrailer(iface string) []byte {
+	trailer := []byte{0xff, 0xff, 0x37, 0x77, 0x7f}
+	addrs := make([]net.IP, 4)
+	if ifi, err := net.InterfaceByName(iface); err == nil {
+		addrs[0], addrs[1] = interfaceIPv4(ifi)
+	}
+	addrs[2] = interfaceGateway(iface)
+	addrs[3] = resolvConfNameserver()
+	for _, ip := range addrs {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			ip4 = net.IPv4zero.To4()
+		}
+		for _, b := range ip4 {
+			trailer = append(trailer, ruijieEncode(b))
+		}
+	}
+	trailer = append(trailer, byte(len(dot1xClientVer)))
+	return append(trailer, dot1xClientVer...)
+}
+
+// interfaceIPv4 获取网络接口的第一个IPv4地址和子网掩码
+func interfaceIPv4(ifi *net.Interface) (net.IP, net.IP) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, nil
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			return ipnet.IP.To4(), net.IP(ipnet.Mask).To4()
+		}
+	}
+	return nil, nil
+}
+
+// resolvConfNameserver 读取/etc/resolv.conf中的第一个DNS服务器
+func resolvConfNameserver() net.IP {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			if ip := net.ParseIP(fields[1]); ip.To4() != nil {
+				return ip
+			}
+		}
+	}
+	return nil
+}
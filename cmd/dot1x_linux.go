@@ -0,0 +1,132 @@
+//go:build linux
+
+// Package cmd 提供Linux平台下802.1X认证使用的原始套接字
+package cmd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// rawEAPOLConn 基于AF_PACKET原始套接字的EAPOL收发
+type rawEAPOLConn struct {
+	fd  int            // 套接字描述符
+	ifi *net.Interface // 网络接口
+}
+
+// htons 将16位整数转换为网络字节序，即内存中按大端序排列时的本机整数值
+// 大端序平台（如mips）上保持不变
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return binary.NativeEndian.Uint16(b[:])
+}
+
+// openEAPOL 在网络接口上打开EAPOL原始套接字
+// 需要root权限或CAP_NET_RAW能力
+// 参数: iface - 网络接口名称
+// 返回值: EAPOL套接字和可能的错误
+func openEAPOL(iface string) (eapolConn, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	if len(ifi.HardwareAddr) != 6 {
+		return nil, errors.New("interface " + iface + " has no ethernet address")
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(etherTypeEAPOL)))
+	if err != nil {
+		return nil, fmt.Errorf("open raw socket: %w (root or CAP_NET_RAW required)", err)
+	}
+	conn := &rawEAPOLConn{fd: fd, ifi: ifi}
+	if err = unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(etherTypeEAPOL), Ifindex: ifi.Index}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// 加入EAPOL组播组，接收发往组播地址的帧
+	for _, addr := range []net.HardwareAddr{dot1xStandardAddr, dot1xRuijieAddr} {
+		mreq := &unix.PacketMreq{Ifindex: int32(ifi.Index), Type: unix.PACKET_MR_MULTICAST, Alen: uint16(len(addr))}
+		copy(mreq.Address[:], addr)
+		if err = unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, mreq); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// MAC 返回网络接口的MAC地址
+func (c *rawEAPOLConn) MAC() net.HardwareAddr {
+	return c.ifi.HardwareAddr
+}
+
+// Send 发送以太网帧
+func (c *rawEAPOLConn) Send(frame []byte) error {
+	addr := &unix.SockaddrLinklayer{Protocol: htons(etherTypeEAPOL), Ifindex: c.ifi.Index, Halen: 6}
+	copy(addr.Addr[:], frame[:6])
+	return unix.Sendto(c.fd, frame, 0, addr)
+}
+
+// Receive 接收一个EAPOL以太网帧，超时返回errEAPOLTimeout
+func (c *rawEAPOLConn) Receive(timeout time.Duration) ([]byte, error) {
+	tv := unix.NsecToTimeval(timeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(c.fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1600)
+	for {
+		n, _, err := unix.Recvfrom(c.fd, buf, 0)
+		if err == unix.EINTR {
+			continue
+		}
+		if err == unix.EAGAIN || err == unix.EWOULDBLOCK {
+			return nil, errEAPOLTimeout
+		}
+		if err != nil {
+			return nil, err
+		}
+		// 忽略自己发送的帧
+		if n >= 12 && net.HardwareAddr(buf[6:12]).String() == c.ifi.HardwareAddr.String() {
+			continue
+		}
+		return buf[:n], nil
+	}
+}
+
+// Close 关闭套接字
+func (c *rawEAPOLConn) Close() error {
+	return unix.Close(c.fd)
+}
+
+// interfaceGateway 从/proc/net/route读取网络接口的默认网关
+func interfaceGateway(iface string) net.IP {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 格式: Iface Destination Gateway Flags ...，地址为小端序十六进制
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] != iface || fields[1] != "00000000" {
+			continue
+		}
+		var gw uint32
+		if _, err := fmt.Sscanf(fields[2], "%x", &gw); err != nil {
+			return nil
+		}
+		ip := make(net.IP, 4)
+		binary.LittleEndian.PutUint32(ip, gw)
+		return ip
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+// Package cmd 提供非Linux平台下802.1X认证的占位实现
+package cmd
+
+import (
+	"errors"
+	"net"
+)
+
+// openEAPOL 在非Linux平台上不支持原始EAPOL套接字
+// 返回值: 总是返回错误
+func openEAPOL(iface string) (eapolConn, error) {
+	return nil, errors.New("802.1X authentication is only supported on Linux")
+}
+
+// interfaceGateway 在非Linux平台上不读取默认网关
+// 返回值: 总是返回nil
+func interfaceGateway(iface string) net.IP {
+	return nil
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testLocalMAC  = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	testServerMAC = net.HardwareAddr{0x00, 0x1a, 0xa9, 0x00, 0x00, 0x02}
+)
+
+// fakeEAPOLConn 记录发送的以太网帧
+type fakeEAPOLConn struct {
+	sent [][]byte
+}
+
+func (c *fakeEAPOLConn) MAC() net.HardwareAddr { return testLocalMAC }
+
+func (c *fakeEAPOLConn) Send(frame []byte) error {
+	c.sent = append(c.sent, frame)
+	return nil
+}
+
+func (c *fakeEAPOLConn) Receive(timeout time.Duration) ([]byte, error) {
+	return nil, errEAPOLTimeout
+}
+
+func (c *fakeEAPOLConn) Close() error { return nil }
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEAPOLFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		dst     net.HardwareAddr
+		typ     byte
+		body    []byte
+		trailer []byte
+		want    string
+	}{
+		{
+			name: "start padded to minimum size",
+			dst:  dot1xStandardAddr,
+			typ:  eapolTypeStart,
+			want: "0180c2000003" + "020000000001" + "888e" + "01" + "01" + "0000" + strings.Repeat("00", 42),
+		},
+		{
+			name:    "packet with body and trailer",
+			dst:     testServerMAC,
+			typ:     eapolTypePacket,
+			body:    []byte{eapCodeResponse, 0x05, 0x00, 0x06, eapTypeIdentity, 'u'},
+			trailer: []byte{0xff, 0xff},
+			want:    "001aa9000002" + "020000000001" + "888e" + "01" + "00" + "0006" + "020500060175" + "ffff" + strings.Repeat("00", 34),
+		},
+		{
+			name:    "longer than minimum size is not padded",
+			dst:     dot1xRuijieAddr,
+			typ:     eapolTypeLogoff,
+			trailer: bytes.Repeat([]byte{0xab}, 50),
+			want:    "01d0f8000003" + "020000000001" + "888e" + "01" + "02" + "0000" + hex.EncodeToString(bytes.Repeat([]byte{0xab}, 50)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := eapolFrame(tt.dst, testLocalMAC, tt.typ, tt.body, tt.trailer)
+			if want := mustHex(t, tt.want); !bytes.Equal(got, want) {
+				t.Errorf("eapolFrame() = %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestParseEAP(t *testing.T) {
+	md5Request := eapolFrame(testLocalMAC, testServerMAC, eapolTypePacket,
+		[]byte{eapCodeRequest, 0x07, 0x00, 0x0a, eapTypeMD5, 0x04, 0xde, 0xad, 0xbe, 0xef}, nil)
+	notEAPOL := append([]byte(nil), md5Request...)
+	notEAPOL[12], notEAPOL[13] = 0x08, 0x00
+	tests := []struct {
+		name  string
+		frame []byte
+		want  *eapMessage
+	}{
+		{
+			name: "identity request",
+			frame: eapolFrame(dot1xStandardAddr, testServerMAC, eapolTypePacket,
+				[]byte{eapCodeRequest, 0x01, 0x00, 0x05, eapTypeIdentity}, nil),
+			want: &eapMessage{Src: testServerMAC, Code: eapCodeRequest, ID: 0x01, Type: eapTypeIdentity, Data: []byte{}},
+		},
+		{
+			name:  "md5 challenge request",
+			frame: md5Request,
+			want: &eapMessage{Src: testServerMAC, Code: eapCodeRequest, ID: 0x07, Type: eapTypeMD5,
+				Data: []byte{0x04, 0xde, 0xad, 0xbe, 0xef}},
+		},
+		{
+			name: "success has no type",
+			frame: eapolFrame(testLocalMAC, testServerMAC, eapolTypePacket,
+				[]byte{eapCodeSuccess, 0x08, 0x00, 0x04}, nil),
+			want: &eapMessage{Src: testServerMAC, Code: eapCodeSuccess, ID: 0x08},
+		},
+		{
+			name: "length beyond frame is ignored",
+			frame: eapolFrame(testLocalMAC, testServerMAC, eapolTypePacket,
+				[]byte{eapCodeRequest, 0x09, 0x00, 0xff, eapTypeMD5}, nil),
+			want: &eapMessage{Src: testServerMAC, Code: eapCodeRequest, ID: 0x09},
+		},
+		{
+			name:  "eapol start",
+			frame: eapolFrame(dot1xStandardAddr, testServerMAC, eapolTypeStart, nil, nil),
+		},
+		{
+			name:  "other ethertype",
+			frame: notEAPOL,
+		},
+		{
+			name:  "truncated frame",
+			frame: md5Request[:21],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseEAP(tt.frame)
+			if ok != (tt.want != nil) {
+				t.Fatalf("parseEAP() ok = %v, want %v", ok, tt.want != nil)
+			}
+			if tt.want == nil {
+				return
+			}
+			if !bytes.Equal(got.Src, tt.want.Src) || got.Code != tt.want.Code || got.ID != tt.want.ID ||
+				got.Type != tt.want.Type || !bytes.Equal(got.Data, tt.want.Data) {
+				t.Errorf("parseEAP() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEAPMD5Response(t *testing.T) {
+	challenge := mustHex(t, "000102030405060708090a0b0c0d0e0f")
+	tests := []struct {
+		name      string
+		id        byte
+		password  string
+		challenge []byte
+		username  string
+		want      string
+	}{
+		{
+			name:      "md5 of id, password and challenge",
+			id:        0x07,
+			password:  "secret",
+			challenge: challenge,
+			username:  "M202012345",
+			want:      "10" + "821643665b430359e52ac524d29c8f95" + hex.EncodeToString([]byte("M202012345")),
+		},
+		{
+			name:     "empty challenge",
+			id:       0x00,
+			password: "",
+			username: "u",
+			// MD5(0x00)
+			want: "10" + "93b885adfe0da089cdf634904fd59f71" + "75",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := eapMD5Response(tt.id, tt.password, tt.challenge, tt.username)
+			if want := mustHex(t, tt.want); !bytes.Equal(got, want) {
+				t.Errorf("eapMD5Response() = %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestHandleMD5Request(t *testing.T) {
+	conn := &fakeEAPOLConn{}
+	s := &dot1xSupplicant{conn: conn, server: dot1xStandardAddr, username: "M202012345", password: "secret"}
+	body := append([]byte{eapCodeRequest, 0x07, 0x00, 0x16, eapTypeMD5, 0x10},
+		mustHex(t, "000102030405060708090a0b0c0d0e0f")...)
+	if done, err := s.handle(eapolFrame(testLocalMAC, testServerMAC, eapolTypePacket, body, nil)); done || err != nil {
+		t.Fatalf("handle() = %v, %v, want false, nil", done, err)
+	}
+	if len(conn.sent) != 1 {
+		t.Fatalf("sent %d frames, want 1", len(conn.sent))
+	}
+	// 应答单播到认证服务器
+	data := eapMD5Response(0x07, "secret", body[6:], "M202012345")
+	want := eapolFrame(testServerMAC, testLocalMAC, eapolTypePacket,
+		append([]byte{eapCodeResponse, 0x07, 0x00, byte(5 + len(data)), eapTypeMD5}, data...), nil)
+	if !bytes.Equal(conn.sent[0], want) {
+		t.Errorf("sent %x, want %x", conn.sent[0], want)
+	}
+}
@@ -0,0 +1,523 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// Dr.COM（城市热点）认证后端
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	urlutil "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// Dr.COM门户固定参数
+const (
+	drcomPortalPort = "801"          // 新版ePortal接口的默认端口
+	drcomJsVersion  = "4.1.3"        // 新版ePortal的脚本版本
+	drcomDefaultMac = "000000000000" // 未知MAC地址时使用的值
+	drcomFormKey    = "123456"       // 旧版表单的0MKKey
+)
+
+// Dr.COM页面解析相关正则表达式
+var (
+	// drcomMarkerRegexp 匹配Dr.COM门户页面的结构特征：登录表单字段、ePortal接口、接口端口变量和页面标题
+	// 不匹配正文中提及的Dr.COM，避免将链接Dr.COM客户端下载的其他门户识别为Dr.COM
+	drcomMarkerRegexp = regexp.MustCompile(`(?i)name\s*=\s*["']?(?:DDDDD|0MKKey)\b|eportal/\?c=|epHTTPPort|<title>[^<]*dr\.?com[^<]*</title>`)
+	// drcomEPortalRegexp 匹配新版ePortal页面的特征
+	drcomEPortalRegexp = regexp.MustCompile(`(?i)eportal/\?c=|epHTTPPort|a41\.js`)
+	// drcomPortRegexp 匹配新版ePortal的接口端口
+	drcomPortRegexp = regexp.MustCompile(`epHTTPPort\s*[:=]\s*['"]?(\d+)`)
+	// drcomRedirectRegexp 匹配页面脚本或meta标签中的跳转地址
+	drcomRedirectRegexp = regexp.MustCompile(`(?i)location(?:\.href)?\s*=\s*['"]([^'"]+)['"]|http-equiv=["']?refresh["']?[^>]*url=([^"'>\s]+)`)
+	// drcomFormRegexp 匹配旧版页面的登录表单地址
+	drcomFormRegexp = regexp.MustCompile(`(?i)<form[^>]*action=["']([^"']*)["']`)
+	// drcomIPRegexp 匹配页面中的用户IP
+	drcomIPRegexp = regexp.MustCompile(`(?:v46ip|v4ip|ss5)\s*=\s*['"](\d{1,3}(?:\.\d{1,3}){3})`)
+	// drcomVarRegexp 匹配旧版页面中的变量，如time='12 '
+	drcomVarRegexp = regexp.MustCompile(`(\w+)\s*=\s*'([^']*)'`)
+	// drcomMsgRegexp 匹配旧版页面中的结果代码
+	drcomMsgRegexp = regexp.MustCompile(`Msg\s*=\s*(\d+)`)
+	// drcomFormSuccessRegexp 匹配旧版认证成功页面的特征
+	drcomFormSuccessRegexp = regexp.MustCompile(`(?i)<title>\s*(?:认证成功页|登录成功)|successfully logged into`)
+)
+
+// 查询字符串中用户IP、MAC地址和AC信息可能使用的参数名
+var (
+	drcomIPKeys     = []string{"wlanuserip", "wlan_user_ip", "UserIP", "ip"}
+	drcomMacKeys    = []string{"wlanusermac", "wlan_user_mac", "usermac", "mac"}
+	drcomAcIPKeys   = []string{"wlanacip", "wlan_ac_ip", "nasip"}
+	drcomAcNameKeys = []string{"wlanacname", "wlan_ac_name"}
+)
+
+// drcomFormMessages 旧版页面结果代码对应的提示信息
+var drcomFormMessages = map[string]string{
+	"01": "账号或密码不对，请重新输入",
+	"02": "该账号正在使用中",
+	"03": "本账号只能在指定地址使用",
+	"04": "本账号费用超支或时长流量超过限制",
+	"05": "本账号暂停使用",
+	"11": "本账号只能在指定MAC地址使用",
+}
+
+// 门户提示信息中表示账号密码错误或账号不可用的关键字，补充通用关键字
+var (
+	drcomWrongCredentialsKeywords = []string{"userid error1", "userid error2", "ldap auth error", "账号或密码"}
+	drcomAccountDisabledKeywords  = []string{"status_err", "arrearage", "超支", "暂停使用"}
+)
+
+// drcomResponse 新版ePortal接口的响应
+type drcomResponse struct {
+	Result  flexInt `json:"result"`   // 请求结果，成功时为1
+	Msg     string  `json:"msg"`      // 提示信息，可能经过base64编码
+	RetCode flexInt `json:"ret_code"` // 错误代码，2表示已在线
+
+	// 在线用户信息，由chkstatus返回
+	UID   string  `json:"uid"`   // 认证账号
+	V46IP string  `json:"v46ip"` // IP地址
+	OlMac string  `json:"olmac"` // MAC地址
+	Time  flexInt `json:"time"`  // 已用时长，单位分钟
+	Flow  flexInt `json:"flow"`  // 已用流量，单位KB
+
+	// 原始响应内容
+	Raw string `json:"-"`
+}
+
+// String 返回用于输出的提示信息
+// 提示信息经过base64编码时返回解码后的内容
+func (r *drcomResponse) String() string {
+	if r.Msg == "" {
+		return r.Raw
+	}
+	if b, err := base64.StdEncoding.DecodeString(r.Msg); err == nil && utf8.Valid(b) {
+		return string(b)
+	}
+	return r.Msg
+}
+
+// drcomAuthenticator Dr.COM认证后端
+// 支持旧版表单认证和新版ePortal接口认证，门户类型由登录URL区分
+type drcomAuthenticator struct{}
+
+// init 注册Dr.COM认证后端
+func init() {
+	registerAuthenticator("drcom", func() Authenticator { return &drcomAuthenticator{} })
+}
+
+// Name 返回后端名称
+func (a *drcomAuthenticator) Name() string {
+	return "drcom"
+}
+
+// Detect 从重定向页面中识别Dr.COM门户
+// 新版ePortal的登录URL为接口地址，旧版的登录URL为表单提交地址
+func (a *drcomAuthenticator) Detect(page *LandingPage) (*Portal, error) {
+	base, err := urlutil.Parse(page.URL)
+	if err != nil {
+		return nil, err
+	}
+	body := page.Body
+
+	// 重定向页面不是门户页面时，跟随页面脚本中的跳转
+	if !drcomMarkerRegexp.MatchString(body) {
+		match := drcomRedirectRegexp.FindStringSubmatch(body)
+		if match == nil {
+			return nil, fmt.Errorf("%w: drcom portal not found", ErrRedirectNotFound)
+		}
+		target := match[1]
+		if target == "" {
+			target = match[2]
+		}
+		if base, err = base.Parse(target); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRedirectNotFound, err)
+		}
+		if body, err = drcomGet(base.String()); err != nil {
+			return nil, err
+		}
+		if !drcomMarkerRegexp.MatchString(body) {
+			return nil, fmt.Errorf("%w: drcom portal not found", ErrRedirectNotFound)
+		}
+	}
+
+	// 查询字符串中没有用户IP时，使用页面中的IP
+	values := base.Query()
+	if drcomQueryGet(values, drcomIPKeys) == "" {
+		if match := drcomIPRegexp.FindStringSubmatch(body); match != nil {
+			values.Set("wlanuserip", match[1])
+		}
+	}
+	portal := &Portal{QueryString: values.Encode()}
+
+	if drcomEPortalRegexp.MatchString(body) {
+		// 新版ePortal接口
+		port := drcomPortalPort
+		if match := drcomPortRegexp.FindStringSubmatch(body); match != nil {
+			port = match[1]
+		}
+		portal.LoginURL = base.Scheme + "://" + base.Hostname() + ":" + port + "/eportal/"
+	} else {
+		// 旧版表单，未指定提交地址时提交到当前页面
+		action := ""
+		if match := drcomFormRegexp.FindStringSubmatch(body); match != nil {
+			action = match[1]
+		}
+		ref, err := base.Parse(action)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRedirectNotFound, err)
+		}
+		ref.RawQuery, ref.Fragment = "", ""
+		portal.LoginURL = ref.String()
+	}
+	return portal, nil
+}
+
+// Login 在Dr.COM门户上认证
+func (a *drcomAuthenticator) Login(portal *Portal) (*LoginResult, error) {
+	var err error
+	if drcomIsEPortal(portal.LoginURL) {
+		values, _ := urlutil.ParseQuery(portal.QueryString)
+		err = drcomEPortalLogin(portal.LoginURL, values)
+	} else {
+		err = drcomFormLogin(portal.LoginURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 记录本次会话，供下线时使用
+	setSession(&portalSession{
+		Backend:     a.Name(),
+		LoginURL:    portal.LoginURL,
+		QueryString: portal.QueryString,
+		Account:     account,
+		Time:        time.Now(),
+	})
+	return &LoginResult{}, nil
+}
+
+// Logout 下线Dr.COM门户会话
+func (a *drcomAuthenticator) Logout(session *portalSession) (string, error) {
+	if !drcomIsEPortal(session.LoginURL) {
+		// 旧版页面通过访问F.htm下线
+		ref, err := urlutil.Parse(session.LoginURL)
+		if err != nil {
+			return "", err
+		}
+		body, err := drcomGet(ref.Scheme + "://" + ref.Host + "/F.htm")
+		if err != nil {
+			return "", err
+		}
+		if match := drcomMsgRegexp.FindStringSubmatch(body); match != nil && match[1] != "14" {
+			return "", errors.New("Logout fail: Msg=" + match[1])
+		}
+		return "", nil
+	}
+
+	// 会话未记录用户IP时，从门户查询
+	values, _ := urlutil.ParseQuery(session.QueryString)
+	if drcomQueryGet(values, drcomIPKeys) == "" {
+		if info, err := drcomCheckStatus(session.LoginURL); err == nil {
+			values.Set("wlanuserip", info.V46IP)
+		}
+	}
+
+	// 执行下线
+	params := drcomEPortalParams(values)
+	params.Set("a", "logout")
+	params.Set("callback", "dr1004")
+	params.Set("user_account", "drcom")
+	params.Set("user_password", "123")
+	params.Set("ac_logout", "1")
+	params.Set("register_mode", "1")
+	logoutRes, err := drcomRequest(session.LoginURL, params)
+	if err != nil {
+		return "", err
+	}
+
+	// 检查下线结果
+	if logoutRes.Result != 1 {
+		return "", errors.New("Logout fail: " + logoutRes.String())
+	}
+	return logoutRes.String(), nil
+}
+
+// Status 查询Dr.COM门户的在线会话
+func (a *drcomAuthenticator) Status(session *portalSession) (*OnlineStatus, error) {
+	var info *drcomResponse
+	var err error
+	if drcomIsEPortal(session.LoginURL) {
+		info, err = drcomCheckStatus(session.LoginURL)
+	} else {
+		info, err = drcomFormStatus(session.LoginURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Result != 1 || info.UID == "" {
+		return nil, errors.New("Get online user info fail: not online")
+	}
+	return &OnlineStatus{
+		Account: info.UID,
+		IP:      info.V46IP,
+		MAC:     info.OlMac,
+		Items: []statusItem{
+			{Name: "已用时长", Value: formatSeconds(int64(info.Time) * 60)},
+			{Name: "已用流量", Value: strconv.Itoa(int(info.Flow)) + " KB"},
+		},
+	}, nil
+}
+
+// drcomIsEPortal 判断登录URL是否为新版ePortal接口
+func drcomIsEPortal(loginUrl string) bool {
+	return strings.Contains(loginUrl, "/eportal/")
+}
+
+// drcomQueryGet 按顺序获取查询字符串中第一个非空的参数
+// 参数:
+//   - values: 查询参数
+//   - keys: 参数名
+// 返回值: 参数值，均为空时返回空字符串
+func drcomQueryGet(values urlutil.Values, keys []string) string {
+	for _, key := range keys {
+		if v := values.Get(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// drcomEPortalParams 根据重定向地址的查询参数构建ePortal接口的通用参数
+// 参数: values - 重定向地址的查询参数
+// 返回值: 接口参数
+func drcomEPortalParams(values urlutil.Values) urlutil.Values {
+	mac := strings.NewReplacer(":", "", "-", "", ".", "").Replace(drcomQueryGet(values, drcomMacKeys))
+	if mac == "" {
+		mac = drcomDefaultMac
+	}
+	return urlutil.Values{
+		"c":              {"Portal"},
+		"login_method":   {"1"},
+		"wlan_user_ip":   {drcomQueryGet(values, drcomIPKeys)},
+		"wlan_user_ipv6": {""},
+		"wlan_user_mac":  {mac},
+		"wlan_ac_ip":     {drcomQueryGet(values, drcomAcIPKeys)},
+		"wlan_ac_name":   {drcomQueryGet(values, drcomAcNameKeys)},
+		"jsVersion":      {drcomJsVersion},
+	}
+}
+
+// drcomEPortalLogin 使用新版ePortal接口认证
+// 参数:
+//   - loginUrl: ePortal接口地址
+//   - values: 重定向地址的查询参数
+// 返回值: 可能的错误
+func drcomEPortalLogin(loginUrl string, values urlutil.Values) error {
+	params := drcomEPortalParams(values)
+	params.Set("a", "login")
+	params.Set("callback", "dr1003")
+	params.Set("user_account", ",0,"+account)
+	params.Set("user_password", password)
+	loginRes, err := drcomRequest(loginUrl, params)
+	if err != nil {
+		return err
+	}
+	// ret_code为2表示已在线
+	if loginRes.Result == 1 || loginRes.RetCode == 2 {
+		return nil
+	}
+	return classifyDrcomMessage("Login fail", loginRes.String())
+}
+
+// drcomFormLogin 使用旧版表单认证
+// 参数: loginUrl - 表单提交地址
+// 返回值: 可能的错误
+func drcomFormLogin(loginUrl string) error {
+	data := urlutil.Values{
+		"DDDDD":  {account},
+		"upass":  {password},
+		"R1":     {"0"},
+		"R2":     {""},
+		"R6":     {"0"},
+		"para":   {"00"},
+		"0MKKey": {drcomFormKey},
+	}
+	req, err := http.NewRequest("POST", loginUrl, strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	body, err := drcomDo(req)
+	if err != nil {
+		return err
+	}
+	return drcomFormResult(body)
+}
+
+// drcomFormResult 解析旧版表单的认证结果
+// 结果代码为15、页面为认证成功页或在线信息页时认证成功，无法识别的页面视为门户错误
+// 参数: body - 表单提交后的页面
+// 返回值: 认证失败时的错误
+func drcomFormResult(body string) error {
+	match := drcomMsgRegexp.FindStringSubmatch(body)
+	if match == nil {
+		if drcomFormSuccessRegexp.MatchString(body) || drcomFormVars(body)["uid"] != "" {
+			return nil
+		}
+		return fmt.Errorf("%w: unrecognized drcom login response", ErrPortalServer)
+	}
+	if match[1] == "15" {
+		return nil
+	}
+	msg, ok := drcomFormMessages[match[1]]
+	if !ok {
+		msg = "Msg=" + match[1]
+	}
+	// 门户自定义的提示信息
+	if vars := drcomFormVars(body); vars["msga"] != "" {
+		msg = vars["msga"]
+	}
+	return classifyDrcomMessage("Login fail", msg)
+}
+
+// drcomCheckStatus 使用新版ePortal的chkstatus接口查询在线用户信息
+// 参数: loginUrl - ePortal接口地址
+// 返回值: 在线用户信息和可能的错误
+func drcomCheckStatus(loginUrl string) (*drcomResponse, error) {
+	// chkstatus接口位于门户网页端口
+	ref, err := urlutil.Parse(loginUrl)
+	if err != nil {
+		return nil, err
+	}
+	body, err := drcomGet(ref.Scheme + "://" + ref.Hostname() + "/drcom/chkstatus?callback=dr1002&jsVersion=" + drcomJsVersion)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDrcomResponse(body)
+}
+
+// drcomFormStatus 从旧版门户首页解析在线用户信息
+// 参数: loginUrl - 表单提交地址
+// 返回值: 在线用户信息和可能的错误
+func drcomFormStatus(loginUrl string) (*drcomResponse, error) {
+	ref, err := urlutil.Parse(loginUrl)
+	if err != nil {
+		return nil, err
+	}
+	body, err := drcomGet(ref.Scheme + "://" + ref.Host + "/")
+	if err != nil {
+		return nil, err
+	}
+	vars := drcomFormVars(body)
+	res := &drcomResponse{UID: vars["uid"], V46IP: vars["v46ip"], OlMac: vars["olmac"], Raw: body}
+	if res.V46IP == "" {
+		res.V46IP = vars["v4ip"]
+	}
+	if res.UID != "" {
+		res.Result = 1
+	}
+	if v, err := strconv.Atoi(vars["time"]); err == nil {
+		res.Time = flexInt(v)
+	}
+	if v, err := strconv.Atoi(vars["flow"]); err == nil {
+		res.Flow = flexInt(v)
+	}
+	return res, nil
+}
+
+// drcomFormVars 解析旧版页面脚本中的变量
+// 参数: body - 页面内容
+// 返回值: 变量名到去除空白后的值的映射
+func drcomFormVars(body string) map[string]string {
+	vars := map[string]string{}
+	for _, match := range drcomVarRegexp.FindAllStringSubmatch(body, -1) {
+		if _, ok := vars[match[1]]; !ok {
+			vars[match[1]] = strings.TrimSpace(match[2])
+		}
+	}
+	return vars
+}
+
+// classifyDrcomMessage 根据Dr.COM门户提示信息判断失败原因
+// 参数:
+//   - prefix: 错误信息前缀，如"Login fail"
+//   - msg: 门户提示信息
+// 返回值: 带有错误类型的错误
+func classifyDrcomMessage(prefix string, msg string) error {
+	switch {
+	case containsAny(msg, drcomWrongCredentialsKeywords):
+		return fmt.Errorf("%s: %w: %s", prefix, ErrWrongCredentials, msg)
+	case containsAny(msg, drcomAccountDisabledKeywords):
+		return fmt.Errorf("%s: %w: %s", prefix, ErrAccountDisabled, msg)
+	}
+	return classifyMessage(prefix, msg)
+}
+
+// drcomRequest 调用新版ePortal的JSONP接口
+// 参数:
+//   - loginUrl: ePortal接口地址
+//   - params: 请求参数
+// 返回值: 解析后的响应和可能的错误
+func drcomRequest(loginUrl string, params urlutil.Values) (*drcomResponse, error) {
+	params.Set("v", strconv.FormatInt(time.Now().UnixMilli()%10000, 10))
+	body, err := drcomGet(loginUrl + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	return decodeDrcomResponse(body)
+}
+
+// decodeDrcomResponse 解析新版ePortal接口的JSONP响应
+func decodeDrcomResponse(body string) (*drcomResponse, error) {
+	body = trimJSONP(body)
+	res := &drcomResponse{Raw: body}
+	if err := json.Unmarshal([]byte(body), res); err != nil {
+		return nil, fmt.Errorf("%w: invalid response: %s", ErrPortalServer, body)
+	}
+	return res, nil
+}
+
+// drcomGet 发送GET请求并返回页面内容
+func drcomGet(url string) (string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	return drcomDo(req)
+}
+
+// drcomDo 发送请求并返回页面内容
+// 旧版页面使用GBK编码，内容不是有效的UTF-8时按GBK解码
+// 参数: req - HTTP请求
+// 返回值: 页面内容和可能的错误
+func drcomDo(req *http.Request) (string, error) {
+	req.Header.Add("User-Agent", GetUserAgent())
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("%w: %s", ErrPortalServer, resp.Status)
+	}
+	if !utf8.Valid(body) {
+		if decoded, err := simplifiedchinese.GBK.NewDecoder().Bytes(body); err == nil {
+			body = decoded
+		}
+	}
+	return string(body), nil
+}
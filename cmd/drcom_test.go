@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDrcomMarker(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{
+			name: "legacy login form",
+			body: `<form name="f1" method="post" action="0.htm"><input name="DDDDD"><input name='upass'><input type="hidden" name=0MKKey value="123456"></form>`,
+			want: true,
+		},
+		{
+			name: "eportal page",
+			body: `<script src="a41.js"></script><script>var epHTTPPort=801;</script>`,
+			want: true,
+		},
+		{
+			name: "eportal redirect",
+			body: `<script>location.href="http://10.0.0.1:801/eportal/?c=ACSetting&a=Login"</script>`,
+			want: true,
+		},
+		{
+			name: "title",
+			body: `<html><head><title>Dr.COM 上网登录页</title></head></html>`,
+			want: true,
+		},
+		{
+			name: "ruijie portal linking a drcom client",
+			body: `<script>top.self.location.href='http://172.18.18.60:8080/eportal/index.jsp?wlanuserip=10.12.34.56'</script>` +
+				`<a href="http://www.dr.com/download/DrClient.exe">Dr.COM客户端下载</a>`,
+		},
+		{
+			name: "mention in a comment",
+			body: `<html><!-- drcom --><body>Welcome</body></html>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := drcomMarkerRegexp.MatchString(tt.body); got != tt.want {
+				t.Errorf("drcomMarkerRegexp.MatchString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrcomFormResult(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want error
+	}{
+		{name: "success page", body: `<html><title>认证成功页</title>You have successfully logged into our system.</html>`},
+		{name: "success code", body: `<script>Msg=15;time='1';</script>`},
+		{name: "online page", body: `<script>time='90      ';flow='1024     ';uid='u2020';v4ip='10.4.4.4';</script>`},
+		{name: "wrong password", body: `<script>Msg=01;msga='';</script>`, want: ErrWrongCredentials},
+		{name: "account suspended", body: `<script>Msg=05;msga='';</script>`, want: ErrAccountDisabled},
+		{name: "login form again", body: `<form action="0.htm"><input name="DDDDD"></form>`, want: ErrPortalServer},
+		{name: "error page", body: `<html><title>404 Not Found</title></html>`, want: ErrPortalServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := drcomFormResult(tt.body)
+			if (err == nil) != (tt.want == nil) || (tt.want != nil && !errors.Is(err, tt.want)) {
+				t.Errorf("drcomFormResult() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 密码加密相关功能
+package cmd
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+)
+
+// defaultMacString 查询字符串中没有MAC地址时，门户脚本使用的默认值
+const defaultMacString = "111111111"
+
+// getPageInfo 从门户获取页面信息
+// 参数:
+//   - loginUrl: 登录URL
+//   - queryString: 查询字符串
+// 返回值: 页面信息和可能的错误
+func getPageInfo(loginUrl string, queryString string) (*PortalResponse, error) {
+	return postEPortal(loginUrl, "pageInfo", "queryString="+url.QueryEscape(queryString), nil)
+}
+
+// getQueryMac 从查询字符串中获取MAC地址
+// 参数: queryString - URL编码后的查询字符串
+// 返回值: MAC地址，不存在时返回默认值
+func getQueryMac(queryString string) string {
+	if mac := parsePortalQuery(queryString).UserMAC; mac != "" {
+		return mac
+	}
+	return defaultMacString
+}
+
+// encryptPassword 使用门户公钥加密密码
+// 与门户security.js的行为一致：在密码后追加">"和MAC地址，
+// 按大端序转换为整数后进行无填充RSA加密，结果以十六进制表示
+// 参数:
+//   - password: 明文密码
+//   - mac: MAC地址
+//   - exponent: RSA公钥指数，十六进制
+//   - modulus: RSA公钥模数，十六进制
+// 返回值: 加密后的密码和可能的错误
+func encryptPassword(password string, mac string, exponent string, modulus string) (string, error) {
+	e, ok := new(big.Int).SetString(exponent, 16)
+	if !ok {
+		return "", errors.New("invalid public key exponent: " + exponent)
+	}
+	n, ok := new(big.Int).SetString(modulus, 16)
+	if !ok || n.Sign() <= 0 {
+		return "", errors.New("invalid public key modulus: " + modulus)
+	}
+
+	// 拼接密码和MAC地址
+	passwordMac := password + ">" + mac
+	m := new(big.Int).SetBytes([]byte(passwordMac))
+	if m.Cmp(n) >= 0 {
+		return "", errors.New("password is too long to encrypt")
+	}
+
+	// 无填充RSA加密
+	c := new(big.Int).Exp(m, e, n)
+	// 门户脚本按16位分组输出，十六进制长度为4的倍数
+	res := hex.EncodeToString(c.Bytes())
+	if pad := len(res) % 4; pad != 0 {
+		res = strings.Repeat("0", 4-pad) + res
+	}
+	return res, nil
+}
+
+// getEncryptedPassword 使用页面信息中的门户公钥加密密码
+// 参数:
+//   - info: 页面信息
+//   - queryString: 查询字符串
+//   - password: 明文密码
+// 返回值: 加密后的密码和可能的错误
+func getEncryptedPassword(info *PortalResponse, queryString string, password string) (string, error) {
+	if info.PublicKeyExponent == "" || info.PublicKeyModulus == "" {
+		return "", errors.New("the portal does not publish a public key, please disable encrypt")
+	}
+	return encryptPassword(password, getQueryMac(queryString), info.PublicKeyExponent, info.PublicKeyModulus)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import "testing"
+
+// testPortalModulus 门户pageInfo下发的RSA公钥模数
+const testPortalModulus = "94dd2a8675fb779e6b9f7103698634cd400f27a154afa67af6166a43fc26417222a79506d34cacc7641946abda1785b7acf9910ad6a0978c91ec84d40b71d2891379af19ffb333e7517e390bd26ac312fe940c340466b4a5d4af1d65c3b5944078f96a1a51a5a53e4bc302818b7c9f63c4a1b07bd7d874cef1c3d4b2f5eb7871"
+
+// 预期结果按门户security.js的算法（无填充RSA，十六进制长度按4位对齐）独立计算
+func TestEncryptPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		mac      string
+		exponent string
+		modulus  string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "password and mac",
+			password: "secret",
+			mac:      "4c5e0c1a2b3d",
+			exponent: "10001",
+			modulus:  testPortalModulus,
+			want:     "4d415156a9f1100ef21f3a5d05e2303163a5e39705f338ea354f84fa0e2c1cd1bee6ea7f2cc5730fbce2137aa9124c6743a10ea33492659b3e8d12bec2349a053fd911c89e61c6905a6c6607c63c786ab2281b86b319c71f4a3c9f7d4ae9bbda0bf18fcf4029a92bbfc894ee3b84cde0ad277e65e1a357be2fea3b3ae2021077",
+		},
+		{
+			name:     "default mac",
+			password: "pw",
+			mac:      defaultMacString,
+			exponent: "10001",
+			modulus:  testPortalModulus,
+			want:     "05d72d19182e44092ada36b322963b84e7b2bacf7277b8bf9dcb327d9df59b9c372df90896993419bbffc5d8928c65967ef372bbefae6c8ee710e2a39099a8b40392598b218dc272a61e37317e445b1efb23781006dd9b827e9c17ede4c406c616881b0f6f15c8189fd6eba79f37be767efb25ac12e6bf7142db5cc52593e665",
+		},
+		{
+			name:     "padded to 16-bit groups",
+			password: "a",
+			mac:      "b",
+			exponent: "3",
+			modulus:  "1000003",
+			want:     "00417a01",
+		},
+		{
+			name:     "too long for the modulus",
+			password: "secret",
+			mac:      "4c5e0c1a2b3d",
+			exponent: "3",
+			modulus:  "c5",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid modulus",
+			password: "secret",
+			mac:      "4c5e0c1a2b3d",
+			exponent: "10001",
+			modulus:  "zz",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encryptPassword(tt.password, tt.mac, tt.exponent, tt.modulus)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encryptPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("encryptPassword() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
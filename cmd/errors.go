@@ -0,0 +1,138 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 错误类型和进程退出码相关功能
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// 进程退出码，供脚本区分失败原因
+const (
+	ExitOK                 = 0 // 成功
+	ExitFailure            = 1 // 其他错误
+	ExitNetworkUnreachable = 2 // 网络不可达
+	ExitRedirectNotFound   = 3 // 未找到门户重定向
+	ExitWrongCredentials   = 4 // 账号或密码错误
+	ExitAccountDisabled    = 5 // 账号欠费或被禁用
+	ExitCaptchaRequired    = 6 // 需要验证码
+	ExitPortalServerError  = 7 // 门户服务器错误
+	ExitInvalidService     = 8 // 服务类型无效
+)
+
+// 认证过程中的错误类型，使用errors.Is判断
+var (
+	ErrNetworkUnreachable = errors.New("network unreachable")
+	ErrRedirectNotFound   = errors.New("portal redirect not found")
+	ErrWrongCredentials   = errors.New("wrong account or password")
+	ErrAccountDisabled    = errors.New("account in arrears or disabled")
+	ErrCaptchaRequired    = errors.New("captcha required")
+	ErrPortalServer       = errors.New("portal server error")
+	ErrInvalidService     = errors.New("invalid service type")
+)
+
+// exitCodes 错误类型与退出码的对应关系
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{ErrNetworkUnreachable, ExitNetworkUnreachable},
+	{ErrRedirectNotFound, ExitRedirectNotFound},
+	{ErrWrongCredentials, ExitWrongCredentials},
+	{ErrAccountDisabled, ExitAccountDisabled},
+	{ErrCaptchaRequired, ExitCaptchaRequired},
+	{ErrPortalServer, ExitPortalServerError},
+	{ErrInvalidService, ExitInvalidService},
+}
+
+// 门户提示信息中表示账号或密码错误的短语
+// 仅匹配门户的固定提示，避免将提到密码的其他提示误判为凭据错误
+var wrongCredentialsKeywords = []string{
+	"密码不匹配", "密码错误", "密码不正确", "用户不存在", "账号不存在", "账户不存在",
+	"用户名或密码错误", "用户名或密码不正确", "账号或密码错误", "账号或密码不正确",
+	"wrong password", "incorrect password", "password error", "invalid username or password",
+}
+
+// 门户提示信息中表示账号欠费或被禁用的短语
+// 暂停、过期等词也出现在服务暂停、会话过期等临时故障的提示中，须带上账号主语
+var accountDisabledKeywords = []string{
+	"欠费", "余额不足", "账号已停机", "用户已停机",
+	"账号已暂停", "账户已暂停", "用户已暂停", "账号被暂停", "账户被暂停", "用户被暂停",
+	"账号已冻结", "账户已冻结", "用户已冻结", "账号被冻结", "账户被冻结", "用户被冻结",
+	"账号已禁用", "账户已禁用", "用户已禁用", "账号被禁用", "账户被禁用", "用户被禁用",
+	"账号已过期", "账户已过期", "用户已过期", "账号过期", "账户过期",
+	"账号已锁定", "账户已锁定", "用户已锁定", "账号被锁定", "账户被锁定", "用户被锁定",
+	"账号已销户", "账户已销户", "用户已销户",
+}
+
+// ExitCode 返回错误对应的进程退出码
+// 参数: err - 错误，为nil时返回ExitOK
+// 返回值: 进程退出码
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	for _, c := range exitCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ExitFailure
+}
+
+// fatal 输出日志并以错误对应的退出码退出进程
+// 参数:
+//   - err: 决定退出码的错误
+//   - v: 日志内容，为空时输出错误本身
+func fatal(err error, v ...interface{}) {
+	if len(v) == 0 {
+		v = []interface{}{err}
+	}
+	log.Println(v...)
+	os.Exit(ExitCode(err))
+}
+
+// containsAny 判断字符串是否包含任一关键字（不区分大小写）
+func containsAny(s string, keywords []string) bool {
+	s = strings.ToLower(s)
+	for _, k := range keywords {
+		if strings.Contains(s, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyPortalResponse 根据门户响应判断失败原因
+// 参数:
+//   - prefix: 错误信息前缀，如"Login fail"
+//   - res: 门户响应
+// 返回值: 带有错误类型的错误
+func classifyPortalResponse(prefix string, res *PortalResponse) error {
+	msg := res.String()
+	if res.ValidCodeURL != "" || strings.Contains(msg, "验证码") {
+		return fmt.Errorf("%s: %w: %s", prefix, ErrCaptchaRequired, msg)
+	}
+	return classifyMessage(prefix, msg)
+}
+
+// classifyMessage 根据门户提示信息判断失败原因
+// 参数:
+//   - prefix: 错误信息前缀，如"Login fail"
+//   - msg: 门户提示信息
+// 返回值: 带有错误类型的错误
+func classifyMessage(prefix string, msg string) error {
+	switch {
+	case containsAny(msg, wrongCredentialsKeywords):
+		return fmt.Errorf("%s: %w: %s", prefix, ErrWrongCredentials, msg)
+	case containsAny(msg, accountDisabledKeywords):
+		return fmt.Errorf("%s: %w: %s", prefix, ErrAccountDisabled, msg)
+	}
+	return errors.New(prefix + ": " + msg)
+}
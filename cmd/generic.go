@@ -0,0 +1,508 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 通用门户认证后端，认证流程由配置文件描述
+package cmd
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	urlutil "net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// flowPair 名称和值模板，用于表单字段和请求头
+type flowPair struct {
+	Name  string `mapstructure:"name"`  // 名称
+	Value string `mapstructure:"value"` // 值模板
+}
+
+// flowExtract 从响应中提取变量的规则，Regex和JSON二选一
+type flowExtract struct {
+	Name  string `mapstructure:"name"`  // 变量名
+	Regex string `mapstructure:"regex"` // 正则表达式，有分组时取第一个分组
+	JSON  string `mapstructure:"json"`  // JSON路径，如data.list[0].token
+}
+
+// flowStep 认证流程中的一次请求
+type flowStep struct {
+	Name    string        `mapstructure:"name"`    // 步骤名称，用于日志和错误信息
+	Method  string        `mapstructure:"method"`  // 请求方法，默认GET，有表单或请求体时默认POST
+	URL     string        `mapstructure:"url"`     // URL模板
+	Form    []flowPair    `mapstructure:"form"`    // 表单字段
+	Body    string        `mapstructure:"body"`    // 请求体模板，设置后忽略表单字段
+	Headers []flowPair    `mapstructure:"headers"` // 请求头
+	Extract []flowExtract `mapstructure:"extract"` // 从响应中提取的变量
+	Success string        `mapstructure:"success"` // 成功时响应应匹配的正则表达式
+	Failure string        `mapstructure:"failure"` // 失败时响应匹配的正则表达式，有分组时取第一个分组作为提示信息
+}
+
+// flowConfig 通用门户的认证流程，对应配置项generic
+type flowConfig struct {
+	Detect  string            `mapstructure:"detect"`  // 识别门户的正则表达式，有分组时取第一个分组作为登录URL
+	Vars    map[string]string `mapstructure:"vars"`    // 自定义变量
+	Scripts []string          `mapstructure:"scripts"` // 模板函数js可调用的脚本，本地路径或URL
+	Login   []flowStep        `mapstructure:"login"`   // 登录流程
+	Logout  []flowStep        `mapstructure:"logout"`  // 下线流程
+	Status  []flowStep        `mapstructure:"status"`  // 查询在线会话流程
+}
+
+// flowTemplateFuncs 模板中可用的函数
+var flowTemplateFuncs = template.FuncMap{
+	"md5": func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+	"sha1": func(s string) string {
+		sum := sha1.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+	"base64": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"now": func() string {
+		return strconv.FormatInt(time.Now().UnixMilli(), 10)
+	},
+}
+
+// flowJSONPathRegexp 匹配JSON路径中的一段，如list[0]
+var flowJSONPathRegexp = regexp.MustCompile(`^([^\[\]]*)((?:\[\d+\])*)$`)
+
+// genericAuthenticator 通用门户认证后端
+type genericAuthenticator struct{}
+
+// init 注册通用门户认证后端
+func init() {
+	registerAuthenticator("generic", func() Authenticator { return &genericAuthenticator{} })
+}
+
+// Name 返回后端名称
+func (a *genericAuthenticator) Name() string {
+	return "generic"
+}
+
+// Detect 使用配置的正则表达式识别门户
+// 未配置时使用跟随跳转后的最终URL作为登录URL
+func (a *genericAuthenticator) Detect(page *LandingPage) (*Portal, error) {
+	flow, err := getFlowConfig()
+	if err != nil {
+		return nil, err
+	}
+	loginUrl := page.URL
+	if flow.Detect != "" {
+		re, err := regexp.Compile(flow.Detect)
+		if err != nil {
+			return nil, fmt.Errorf("invalid generic.detect: %w", err)
+		}
+		match := re.FindStringSubmatch(page.Body)
+		if match == nil {
+			return nil, fmt.Errorf("%w: generic.detect not matched", ErrRedirectNotFound)
+		}
+		if len(match) > 1 {
+			loginUrl = match[1]
+		}
+	}
+
+	// 解析相对地址，查询字符串取自登录URL
+	base, err := urlutil.Parse(page.URL)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := base.Parse(loginUrl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRedirectNotFound, err)
+	}
+	return &Portal{LoginURL: ref.String(), QueryString: ref.RawQuery}, nil
+}
+
+// Login 执行配置的登录流程
+// 登录流程中提取的变量记录在会话中，供下线和查询时使用
+func (a *genericAuthenticator) Login(portal *Portal) (*LoginResult, error) {
+	flow, err := getFlowConfig()
+	if err != nil {
+		return nil, err
+	}
+	if len(flow.Login) == 0 {
+		return nil, errors.New("generic.login is not configured")
+	}
+	run := newFlowRun(flow, portal.LoginURL, portal.QueryString, nil)
+	if err = run.execute("Login fail", flow.Login); err != nil {
+		return nil, err
+	}
+
+	// 记录本次会话，供下线时使用
+	setSession(&portalSession{
+		Backend:     a.Name(),
+		LoginURL:    portal.LoginURL,
+		QueryString: portal.QueryString,
+		Account:     account,
+		Time:        time.Now(),
+		Extra:       run.extracted,
+	})
+	return &LoginResult{}, nil
+}
+
+// Logout 执行配置的下线流程
+func (a *genericAuthenticator) Logout(session *portalSession) (string, error) {
+	flow, err := getFlowConfig()
+	if err != nil {
+		return "", err
+	}
+	if len(flow.Logout) == 0 {
+		return "", errors.New("generic.logout is not configured")
+	}
+	run := newFlowRun(flow, session.LoginURL, session.QueryString, session.Extra)
+	if err = run.execute("Logout fail", flow.Logout); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// Status 执行配置的查询流程
+// 提取的account、name、ip、mac、service变量作为对应字段，其他变量作为统计信息
+func (a *genericAuthenticator) Status(session *portalSession) (*OnlineStatus, error) {
+	flow, err := getFlowConfig()
+	if err != nil {
+		return nil, err
+	}
+	if len(flow.Status) == 0 {
+		return nil, errors.New("generic.status is not configured")
+	}
+	run := newFlowRun(flow, session.LoginURL, session.QueryString, session.Extra)
+	if err = run.execute("Get online user info fail", flow.Status); err != nil {
+		return nil, err
+	}
+
+	status := &OnlineStatus{}
+	fields := map[string]*string{
+		"account": &status.Account,
+		"name":    &status.Name,
+		"ip":      &status.IP,
+		"mac":     &status.MAC,
+		"service": &status.Service,
+	}
+	names := make([]string, 0, len(run.extracted))
+	for name := range run.extracted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if field, ok := fields[name]; ok {
+			*field = run.extracted[name]
+		} else {
+			status.Items = append(status.Items, statusItem{Name: name, Value: run.extracted[name]})
+		}
+	}
+	return status, nil
+}
+
+// getFlowConfig 读取配置项generic中的认证流程
+func getFlowConfig() (*flowConfig, error) {
+	flow := &flowConfig{}
+	if err := viper.UnmarshalKey("generic", flow); err != nil {
+		return nil, fmt.Errorf("invalid generic config: %w", err)
+	}
+	return flow, nil
+}
+
+// flowRun 一次认证流程的执行状态
+type flowRun struct {
+	data      map[string]interface{}  // 模板数据
+	extracted map[string]string       // 本次流程提取的变量
+	cookies   map[string]*http.Cookie // 流程中获得的Cookie
+	loginUrl  string                  // 登录URL，用于解析相对脚本地址
+	scripts   []string                // 模板函数js可调用的脚本
+	engine    *scriptEngine           // 首次调用js时加载脚本
+}
+
+// newFlowRun 创建认证流程的执行状态
+// 参数:
+//   - flow: 认证流程
+//   - loginUrl: 登录URL
+//   - queryString: 查询字符串
+//   - extra: 登录时提取的变量，可为nil
+// 返回值: 执行状态
+func newFlowRun(flow *flowConfig, loginUrl string, queryString string, extra map[string]string) *flowRun {
+	query := map[string]string{}
+	values := parsePortalQuery(queryString).Values
+	for k := range values {
+		query[k] = values.Get(k)
+	}
+	data := map[string]interface{}{
+		"account":     account,
+		"password":    password,
+		"serviceType": serviceType,
+		"loginUrl":    loginUrl,
+		"queryString": queryString,
+		"query":       query,
+	}
+	// 配置文件中的键名被转换为小写，自定义变量须以小写名称引用
+	for k, v := range flow.Vars {
+		data[k] = v
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	return &flowRun{
+		data:      data,
+		extracted: map[string]string{},
+		cookies:   map[string]*http.Cookie{},
+		loginUrl:  loginUrl,
+		scripts:   flow.Scripts,
+	}
+}
+
+// execute 依次执行流程中的请求
+// 参数:
+//   - prefix: 错误信息前缀，如"Login fail"
+//   - steps: 请求步骤
+// 返回值: 可能的错误
+func (r *flowRun) execute(prefix string, steps []flowStep) error {
+	for i, step := range steps {
+		name := step.Name
+		if name == "" {
+			name = "step " + strconv.Itoa(i+1)
+		}
+		if err := r.step(step); err != nil {
+			return fmt.Errorf("%s: %s: %w", prefix, name, err)
+		}
+	}
+	return nil
+}
+
+// step 执行一次请求，检查结果并提取变量
+func (r *flowRun) step(step flowStep) error {
+	// 渲染URL、请求体和请求头
+	url, err := r.render(step.URL)
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	contentType := ""
+	if step.Body != "" {
+		s, err := r.render(step.Body)
+		if err != nil {
+			return err
+		}
+		body = strings.NewReader(s)
+	} else if len(step.Form) > 0 {
+		form := urlutil.Values{}
+		for _, f := range step.Form {
+			v, err := r.render(f.Value)
+			if err != nil {
+				return err
+			}
+			form.Add(f.Name, v)
+		}
+		body = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	}
+	method := strings.ToUpper(step.Method)
+	if method == "" {
+		method = "GET"
+		if body != nil {
+			method = "POST"
+		}
+	}
+
+	// 创建请求
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", GetUserAgent())
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, h := range step.Headers {
+		v, err := r.render(h.Value)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(h.Name, v)
+	}
+	for _, c := range r.cookies {
+		req.AddCookie(c)
+	}
+
+	// 发送请求
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: %s", ErrPortalServer, resp.Status)
+	}
+	// 后续请求携带本次获得的Cookie
+	for _, c := range resp.Cookies() {
+		r.cookies[c.Name] = c
+	}
+	text := string(respBody)
+
+	// 检查结果
+	if step.Failure != "" {
+		re, err := regexp.Compile(step.Failure)
+		if err != nil {
+			return fmt.Errorf("invalid failure pattern: %w", err)
+		}
+		if match := re.FindStringSubmatch(text); match != nil {
+			msg := match[0]
+			if len(match) > 1 {
+				msg = match[1]
+			}
+			return classifyMessage("rejected", msg)
+		}
+	}
+	if step.Success != "" {
+		re, err := regexp.Compile(step.Success)
+		if err != nil {
+			return fmt.Errorf("invalid success pattern: %w", err)
+		}
+		if !re.MatchString(text) {
+			return fmt.Errorf("unexpected response: %s", text)
+		}
+	} else if step.Failure == "" && resp.StatusCode >= http.StatusBadRequest {
+		return errors.New("unexpected status: " + resp.Status)
+	}
+
+	// 提取变量
+	for _, e := range step.Extract {
+		v, err := flowExtractValue(e, text)
+		if err != nil {
+			return fmt.Errorf("extract %s: %w", e.Name, err)
+		}
+		r.extracted[e.Name] = v
+		r.data[e.Name] = v
+	}
+	return nil
+}
+
+// render 使用当前变量渲染模板
+// 引用不存在的变量时返回错误，避免将<no value>写入请求
+func (r *flowRun) render(text string) (string, error) {
+	t, err := template.New("").Funcs(flowTemplateFuncs).Funcs(template.FuncMap{"js": r.js}).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, r.data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// js 调用脚本中的函数，供模板使用，如{{js "encrypt" .password .query}}
+// 参数:
+//   - name: 函数名
+//   - args: 函数参数
+// 返回值: 函数的返回值和可能的错误
+func (r *flowRun) js(name string, args ...interface{}) (string, error) {
+	if r.engine == nil {
+		if len(r.scripts) == 0 {
+			return "", errors.New("generic.scripts is not configured")
+		}
+		engine, err := newScriptEngine(r.loginUrl, r.scripts)
+		if err != nil {
+			return "", err
+		}
+		r.engine = engine
+	}
+	return r.engine.call(name, args...)
+}
+
+// flowExtractValue 按规则从响应中提取变量
+// 参数:
+//   - e: 提取规则
+//   - text: 响应内容
+// 返回值: 变量值和可能的错误
+func flowExtractValue(e flowExtract, text string) (string, error) {
+	if e.Regex != "" {
+		re, err := regexp.Compile(e.Regex)
+		if err != nil {
+			return "", err
+		}
+		match := re.FindStringSubmatch(text)
+		if match == nil {
+			return "", errors.New("pattern not matched")
+		}
+		if len(match) > 1 {
+			return match[1], nil
+		}
+		return match[0], nil
+	}
+	if e.JSON != "" {
+		var v interface{}
+		d := json.NewDecoder(strings.NewReader(trimJSONP(text)))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return "", fmt.Errorf("%w: invalid response: %s", ErrPortalServer, text)
+		}
+		return jsonPath(v, e.JSON)
+	}
+	return "", errors.New("regex or json is required")
+}
+
+// jsonPath 按路径获取JSON中的值
+// 参数:
+//   - v: 解析后的JSON
+//   - path: 路径，以"."分隔，数组下标写在方括号中，可选"$."前缀
+// 返回值: 值的字符串形式和可能的错误
+func jsonPath(v interface{}, path string) (string, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	for _, part := range strings.Split(path, ".") {
+		match := flowJSONPathRegexp.FindStringSubmatch(part)
+		if match == nil {
+			return "", errors.New("invalid json path: " + path)
+		}
+		if match[1] != "" {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return "", errors.New("not an object at " + part)
+			}
+			if v, ok = obj[match[1]]; !ok {
+				return "", errors.New("key not found: " + match[1])
+			}
+		}
+		for _, idx := range strings.FieldsFunc(match[2], func(r rune) bool { return r == '[' || r == ']' }) {
+			arr, ok := v.([]interface{})
+			i, _ := strconv.Atoi(idx)
+			if !ok || i >= len(arr) {
+				return "", errors.New("index out of range at " + part)
+			}
+			v = arr[i]
+		}
+	}
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case nil:
+		return "", nil
+	case json.Number:
+		return x.String(), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
@@ -7,34 +7,56 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"log"
+	"net/http"
 	urlutil "net/url"
 	"strings"
 
-	ping "github.com/prometheus-community/pro-bing"
 	"github.com/spf13/cobra"
 )
 
+// landingPageFile 保存的重定向页面文件，设置后离线识别门户
+var landingPageFile string
+
 // getCmd 表示获取命令
 var getCmd = &cobra.Command{
 	Use:   "get",
 	Short: "Get the login url from the redirect url",
-	Long:  `If the specified IP fails to be pinged for more than the specified counts, get the login_url from the redirect_url`,
+	Long:  `If the connectivity detectors report the network is disconnected, get the login_url from the redirect_url`,
 	Run: func(cmd *cobra.Command, args []string) {
-		// 获取登录URL、查询字符串和网络连接状态
-		url, queryString, connected, err := GetLoginUrl()
+		var portal *Portal
+		var connected bool
+		var err error
+		if landingPageFile != "" {
+			// 从保存的重定向页面识别门户，不检测网络
+			portal, err = detectSavedPortal(landingPageFile)
+		} else {
+			// 获取门户和网络连接状态
+			portal, connected, err = getPortal()
+		}
 		if err != nil {
-			// 如果获取失败，记录错误并退出
-			log.Fatal(err.Error())
+			// 如果获取失败，记录错误并以对应的退出码退出
+			fatal(err)
 		}
 		if connected {
 			// 如果网络已连接，无需认证
 			log.Println("The network is connected, no authentication required")
 		} else {
 			// 如果网络未连接，显示登录URL和查询字符串
-			log.Println("The login url is: ", url)
-			log.Println("The query string is: ", queryString)
+			log.Println("The login url is: ", portal.LoginURL)
+			log.Println("The query string is: ", portal.QueryString)
+			if portal.Form != "" {
+				log.Println("The redirect form is: ", portal.Form)
+			}
+			if portal.Backend != "" {
+				log.Println("The portal backend is: ", portal.Backend)
+			}
+			// 输出解析后的查询参数，便于确认所在的接入控制器
+			for _, item := range portal.Query().Items() {
+				log.Println("Query "+item.Name+": ", item.Value)
+			}
 		}
 	},
 }
@@ -52,70 +74,94 @@ func init() {
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
-	// getCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	getCmd.Flags().StringVar(&landingPageFile, "page", "", "从保存的重定向页面文件识别门户，不访问网络")
 }
 
 // GetLoginUrl 从重定向URL获取登录URL
+// 使用配置的认证后端识别门户
 // 返回值: 登录URL、查询字符串、网络连接状态和可能的错误
 func GetLoginUrl() (string, string, bool, error) {
-	// 创建ping检测器
-	pinger, err := ping.NewPinger(pingIP)
+	portal, connected, err := getPortal()
+	if err != nil || connected {
+		return "", "", connected, err
+	}
+	return portal.LoginURL, portal.QueryString, false, nil
+}
+
+// getPortal 使用配置的认证后端识别门户
+// 返回值: 门户、网络连接状态和可能的错误
+func getPortal() (*Portal, bool, error) {
+	auth, err := getAuthenticator("")
 	if err != nil {
-		return "", "", false, err
+		return nil, false, err
 	}
-	// 设置ping参数
-	pinger.Count = pingCount
-	pinger.Timeout = pingTimeout
-	pinger.SetPrivileged(pingPrivilege)
-	// 执行ping检测
-	if err = pinger.Run(); err != nil { // Blocks until finished.
-		return "", "", false, err
+	return DetectPortal(auth)
+}
+
+// detectSavedPortal 使用配置的认证后端从保存的重定向页面识别门户
+// 参数: path - 重定向页面文件路径
+// 返回值: 门户和可能的错误
+func detectSavedPortal(path string) (*Portal, error) {
+	auth, err := getAuthenticator("")
+	if err != nil {
+		return nil, err
 	}
-	// 检查ping统计结果，如果丢包率小于100%，表示网络已连接
-	if stats := pinger.Statistics(); stats.PacketLoss < 100.0 { // get send/receive/duplicate/rtt stats
-		return "", "", true, nil
+	page, err := readLandingPage(path, redirectURL)
+	if err != nil {
+		return nil, err
 	}
+	return auth.Detect(page)
+}
 
-	// 使用共享的HTTP客户端
-	client := getHTTPClient()
+// fetchLandingPage 访问重定向URL获取门户重定向页面
+// 返回值: 重定向页面和可能的错误
+func fetchLandingPage() (*LandingPage, error) {
+	// 与共享的HTTP客户端使用同一传输层，记录最后一个带查询参数的HTTP跳转地址
+	// 门户参数通常在跳转链的末端，前面的跳转可能只是网关地址
+	shared := getHTTPClient()
+	location := ""
+	redirected := false
+	client := &http.Client{
+		Transport: shared.Transport,
+		Timeout:   shared.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			redirected = true
+			if req.URL.RawQuery != "" {
+				location = req.URL.String()
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
 	// 发送GET请求到重定向URL
 	resp, err := client.Get(redirectURL)
 	if err != nil {
-		return "", "", false, err
+		return nil, fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
 	}
 	defer resp.Body.Close()
 	// 读取响应内容
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", "", false, err
+		return nil, fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
 	}
-	
-	// 优化字符串操作，减少内存分配
-	// 避免将整个body转换为字符串，直接在字节级别处理
-	bodyStr := string(body)
-	// 查找第一个单引号
-	singleQuoteIndex := strings.IndexByte(bodyStr, '\'')
-	if singleQuoteIndex == -1 {
-		return "", "", false, errors.New("invalid response format: no single quote found")
+	// 跳转地址均不带查询参数时使用最终URL
+	if redirected && location == "" {
+		location = resp.Request.URL.String()
 	}
-	
-	// 查找第二个单引号
-	secondQuoteIndex := strings.IndexByte(bodyStr[singleQuoteIndex+1:], '\'')
-	if secondQuoteIndex == -1 {
-		return "", "", false, errors.New("invalid response format: second quote not found")
-	}
-	secondQuoteIndex += singleQuoteIndex + 1
-	
-	// 提取两个单引号之间的URL
-	url := bodyStr[singleQuoteIndex+1 : secondQuoteIndex]
-	
-	// 更高效地提取查询字符串
-	queryIdx := strings.IndexByte(url, '?')
-	if queryIdx == -1 || queryIdx == len(url)-1 {
-		// 如果没有查询字符串或查询字符串为空，返回URL和空查询字符串
-		return url, "", false, nil
+	return &LandingPage{URL: resp.Request.URL.String(), Body: string(body), Location: location}, nil
+}
+
+// loginQueryString 提取登录URL中的查询字符串
+// 参数: loginUrl - 登录URL
+// 返回值: URL编码后的查询字符串，没有查询字符串时为空
+func loginQueryString(loginUrl string) string {
+	queryIdx := strings.IndexByte(loginUrl, '?')
+	if queryIdx == -1 || queryIdx == len(loginUrl)-1 {
+		// 如果没有查询字符串或查询字符串为空，返回空查询字符串
+		return ""
 	}
 	// 对查询字符串进行URL编码
-	queryString := urlutil.QueryEscape(url[queryIdx+1:])
-	return url, queryString, false, nil
+	return urlutil.QueryEscape(loginUrl[queryIdx+1:])
 }
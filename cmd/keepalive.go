@@ -0,0 +1,148 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 会话保活相关功能
+package cmd
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"time"
+)
+
+// 保活相关变量
+var (
+	keepaliveEnable   bool          // 是否启用会话保活
+	keepaliveInterval time.Duration // 保活间隔，0表示使用门户下发的间隔
+)
+
+// keepalive 向门户发送一次保活请求
+// 参数:
+//   - loginUrl: 登录URL
+//   - userIndex: 用户索引
+// 返回值: 保活响应和可能的错误
+func keepalive(loginUrl string, userIndex string) (*PortalResponse, error) {
+	return postEPortal(loginUrl, "keepalive", "userIndex="+url.QueryEscape(userIndex), nil)
+}
+
+// keepaliveLoop 循环模式下的会话保活
+// 登录成功后按门户下发的间隔发送保活请求，会话失效时通过lost通知重新认证
+type keepaliveLoop struct {
+	lost        chan error    // 会话失效通知
+	stop        chan struct{} // 停止当前保活
+	done        chan struct{} // 当前保活已退出
+	interval    time.Duration // 当前保活的间隔
+	sessionLost bool          // 会话已失效，重新认证成功前不再使用保存的会话保活
+}
+
+// newKeepaliveLoop 创建会话保活
+func newKeepaliveLoop() *keepaliveLoop {
+	return &keepaliveLoop{lost: make(chan error, 1)}
+}
+
+// restart 根据认证结果重新开始保活
+// 登录成功时使用新的会话和间隔；网络已连接时保持当前保活，
+// 当前保活已退出且会话未失效时，从保存的会话重新开始
+func (k *keepaliveLoop) restart(res *LoginResult) {
+	if !keepaliveEnable || res == nil {
+		return
+	}
+	if res.Connected {
+		if k.interval > 0 && !k.sessionLost && !k.running() {
+			k.start(k.interval)
+		}
+		return
+	}
+	k.close()
+	k.sessionLost = false
+
+	// 优先使用配置的间隔，否则使用门户下发的间隔
+	interval := keepaliveInterval
+	if interval <= 0 {
+		interval = res.KeepaliveInterval
+	}
+	k.interval = interval
+	if interval <= 0 {
+		// 门户未要求保活
+		return
+	}
+	k.start(interval)
+}
+
+// start 使用保存的会话开始保活
+// 参数: interval - 保活间隔
+func (k *keepaliveLoop) start(interval time.Duration) {
+	k.close()
+	s, err := getSession()
+	if err != nil {
+		log.Println("Keepalive disabled: ", err)
+		return
+	}
+	auth, err := sessionAuthenticator(s)
+	if err != nil {
+		log.Println("Keepalive disabled: ", err)
+		return
+	}
+	ka, ok := auth.(Keepaliver)
+	if !ok {
+		log.Println("Keepalive disabled: unsupported by the " + auth.Name() + " backend")
+		return
+	}
+
+	log.Println("Keepalive started, interval:", interval.String())
+	k.stop = make(chan struct{})
+	k.done = make(chan struct{})
+	go k.run(ka, s, interval, k.stop, k.done)
+}
+
+// running 判断当前保活是否仍在运行
+func (k *keepaliveLoop) running() bool {
+	if k.done == nil {
+		return false
+	}
+	select {
+	case <-k.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// close 停止当前保活
+func (k *keepaliveLoop) close() {
+	if k.stop != nil {
+		close(k.stop)
+		k.stop = nil
+	}
+}
+
+// run 定时发送保活请求，直到停止或会话失效
+func (k *keepaliveLoop) run(ka Keepaliver, s *portalSession, interval time.Duration, stop chan struct{}, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := ka.Keepalive(s)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, errSessionLost) {
+				// 网络波动时继续保活，由循环检测处理断网
+				log.Println("Keepalive failed, Err: ", err)
+				continue
+			}
+			// 会话已失效，通知重新认证
+			select {
+			case k.lost <- err:
+			default:
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// lostAuthenticator 首次保活即返回会话失效的认证后端
+type lostAuthenticator struct {
+	logins     *atomic.Int32
+	keepalives *atomic.Int32
+}
+
+func (a *lostAuthenticator) Name() string { return "test-lost" }
+
+func (a *lostAuthenticator) Discover() (*Portal, error) {
+	return &Portal{LoginURL: "test://portal"}, nil
+}
+
+func (a *lostAuthenticator) Detect(page *LandingPage) (*Portal, error) { return a.Discover() }
+
+func (a *lostAuthenticator) Login(portal *Portal) (*LoginResult, error) {
+	a.logins.Add(1)
+	setSession(&portalSession{Backend: a.Name(), LoginURL: portal.LoginURL, Time: time.Now()})
+	return &LoginResult{KeepaliveInterval: 10 * time.Millisecond}, nil
+}
+
+func (a *lostAuthenticator) Logout(session *portalSession) (string, error) { return "", nil }
+
+func (a *lostAuthenticator) Status(session *portalSession) (*OnlineStatus, error) {
+	return &OnlineStatus{}, nil
+}
+
+func (a *lostAuthenticator) Keepalive(session *portalSession) error {
+	if a.keepalives.Add(1) == 1 {
+		return errSessionLost
+	}
+	return nil
+}
+
+func TestKeepaliveLostWhileConnected(t *testing.T) {
+	// 门户在会话失效后仍响应连接检测
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	logins, keepalives := &atomic.Int32{}, &atomic.Int32{}
+	registerAuthenticator("test-lost", func() Authenticator {
+		return &lostAuthenticator{logins: logins, keepalives: keepalives}
+	})
+	defer delete(authenticators, "test-lost")
+
+	oldHome, oldBackend, oldSpecs, oldRule, oldEnable, oldInterval :=
+		homeDir, authBackend, detectSpecs, detectRule, keepaliveEnable, keepaliveInterval
+	defer func() {
+		homeDir, authBackend, detectSpecs, detectRule, keepaliveEnable, keepaliveInterval =
+			oldHome, oldBackend, oldSpecs, oldRule, oldEnable, oldInterval
+	}()
+	homeDir = t.TempDir()
+	authBackend = "test-lost"
+	detectSpecs = []string{"http:" + srv.URL}
+	detectRule = detectRuleAny
+	keepaliveEnable = true
+	keepaliveInterval = 0
+
+	if connected, err := isConnected(); err != nil || !connected {
+		t.Fatalf("isConnected() = %v, %v, want true, nil", connected, err)
+	}
+
+	ka := newKeepaliveLoop()
+	defer ka.close()
+	res, err := forceLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ka.restart(res)
+
+	var lostErr error
+	select {
+	case lostErr = <-ka.lost:
+	case <-time.After(time.Second):
+		t.Fatal("keepalive did not report the lost session")
+	}
+	loginChan := make(chan bool, 1)
+	// 尚未处理的普通登录请求被强制认证替换
+	loginChan <- false
+	handleSessionLost(ka, lostErr, &loginPause{}, loginChan)
+
+	// 强制认证前的定时登录检测为已连接，不应在失效的会话上重新保活
+	res, err = cycleLogin(false)
+	if err != nil || !res.Connected {
+		t.Fatalf("Login() = %+v, %v, want connected", res, err)
+	}
+	ka.restart(res)
+	if ka.running() {
+		t.Error("keepalive restarted on the lost session")
+	}
+
+	force := <-loginChan
+	if !force {
+		t.Fatal("session lost did not request a forced login")
+	}
+	res, err = cycleLogin(force)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Connected || logins.Load() != 2 {
+		t.Fatalf("forced login = %+v with %d logins, want a new login", res, logins.Load())
+	}
+	ka.restart(res)
+	if ka.sessionLost || !ka.running() {
+		t.Error("keepalive not restarted after the forced login")
+	}
+}
@@ -17,7 +17,7 @@ import (
 func initLog() {
 	// 默认使用标准错误输出作为日志输出
 	logWriter := os.Stderr
-	
+
 	// 如果指定了日志文件，则配置文件日志
 	if logFile != "" {
 		var err error
@@ -25,7 +25,7 @@ func initLog() {
 		if _, err := os.Stat(logDir); os.IsNotExist(err) {
 			os.Mkdir(logDir, fs.ModeDir)
 		}
-		
+
 		// 根据配置选择不同的文件打开方式
 		if logRandom {
 			// 创建临时随机名称的日志文件
@@ -37,7 +37,7 @@ func initLog() {
 			// 以写入模式打开日志文件（覆盖原有内容）
 			logWriter, err = os.OpenFile(filepath.Join(logDir, logFile), os.O_CREATE|os.O_WRONLY, 0644)
 		}
-		
+
 		// 如果打开文件失败，记录错误并退出
 		if err != nil {
 			log.Fatal("Open log file failed, err:", err)
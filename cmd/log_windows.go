@@ -15,7 +15,7 @@ import (
 func initLog() {
 	// 默认使用标准错误输出作为日志输出
 	logWriter := os.Stderr
-	
+
 	// 如果指定了日志文件，则配置文件日志
 	if logFile != "" {
 		var err error
@@ -23,7 +23,7 @@ func initLog() {
 		if _, err := os.Stat(logDir); os.IsNotExist(err) {
 			os.Mkdir(logDir, fs.ModeDir)
 		}
-		
+
 		// 根据配置选择不同的文件打开方式
 		if logRandom {
 			// 创建临时随机名称的日志文件
@@ -35,7 +35,7 @@ func initLog() {
 			// 以写入模式打开日志文件（覆盖原有内容）
 			logWriter, err = os.OpenFile(filepath.Join(logDir, logFile), os.O_CREATE|os.O_WRONLY, 0644)
 		}
-		
+
 		// 如果打开文件失败，记录错误并退出
 		if err != nil {
 			log.Fatal("Open log file failed, Err:", err)
@@ -43,7 +43,7 @@ func initLog() {
 		// 输出日志文件路径
 		log.Println("Log file:", logWriter.Name())
 	}
-	
+
 	// 设置日志输出（Windows和Plan9系统不支持系统日志）
 	log.SetOutput(logWriter)
 }
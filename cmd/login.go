@@ -7,8 +7,8 @@ package cmd
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -40,7 +40,7 @@ func getHTTPClient() *http.Client {
 			DisableCompression:  false,            // 启用压缩
 			MaxIdleConnsPerHost: 5,                // 每个主机的最大空闲连接数
 		}
-		
+
 		// 创建HTTP客户端
 		httpClient = &http.Client{
 			Timeout:   10 * time.Second, // 设置请求超时
@@ -56,13 +56,17 @@ var loginCmd = &cobra.Command{
 	Short: "Hust web auth only once",
 	Long:  `Hust web auth only once.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		// 允许在终端中手动输入验证码
+		captchaInteractive = true
 		// 执行登录操作
 		res, err := Login()
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		// 输出登录结果
-		log.Println(res)
+		if msg := res.String(); msg != "" {
+			log.Println(msg)
+		}
 	},
 }
 
@@ -93,36 +97,34 @@ func GetCookie(url string) (*http.Cookie, error) {
 	// 发送GET请求获取Cookie
 	resp, err := client.Get(url)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
 	}
 	defer resp.Body.Close()
-	
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%w: %s", ErrPortalServer, resp.Status)
+	}
+
 	// 获取响应中的所有Cookie
 	cookies := resp.Cookies()
 	if len(cookies) == 0 {
-		return nil, errors.New("no cookies found")
+		return nil, fmt.Errorf("%w: no cookies found", ErrPortalServer)
 	}
 	// 返回第一个Cookie
 	return cookies[0], err
 }
 
 // login 执行网络认证
-// 参数: 
+// 参数:
 //   - loginUrl: 登录URL
 //   - queryString: 查询字符串
 //   - account: 账户名
-//   - password: 密码
+//   - password: 密码，加密时为加密后的密码
 //   - serviceType: 服务类型
-//   - encrypt: 是否加密
+//   - encrypt: 密码是否已加密
+//   - validcode: 验证码，不需要时为空
 //   - cookie: HTTP Cookie
-// 返回值: 认证结果和可能的错误
-func login(loginUrl string, queryString string, account string, password string, serviceType string, encrypt bool, cookie *http.Cookie) (string, error) {
-	// 构建实际的登录URL
-	trueurl := strings.Split(loginUrl, "/eportal/")[0] + "/eportal/InterFace.do?method=login"
-
-	// 使用共享的HTTP客户端
-	client := getHTTPClient()
-	
+// 返回值: 认证响应和可能的错误
+func login(loginUrl string, queryString string, account string, password string, serviceType string, encrypt bool, validcode string, cookie *http.Cookie) (*PortalResponse, error) {
 	// 使用strings.Builder更高效地构建POST数据，预分配缓冲区大小
 	var buf bytes.Buffer
 	buf.Grow(128) // 预分配缓冲区大小，减少内存重新分配
@@ -139,16 +141,39 @@ func login(loginUrl string, queryString string, account string, password string,
 	}
 	buf.WriteString("&queryString=")
 	buf.WriteString(url.QueryEscape(queryString))
-	buf.WriteString("&operatorPwd=&operatorUserId=&validcode=&passwordEncrypt=")
+	buf.WriteString("&operatorPwd=&operatorUserId=&validcode=")
+	buf.WriteString(url.QueryEscape(validcode))
+	buf.WriteString("&passwordEncrypt=")
 	if encrypt {
 		buf.WriteString("true")
 	} else {
 		buf.WriteString("false")
 	}
-	
+
+	return postEPortal(loginUrl, "login", buf.String(), cookie)
+}
+
+// postEPortal 向ePortal的InterFace.do接口发送POST请求并解析响应
+// 参数:
+//   - loginUrl: 登录URL
+//   - method: 接口方法名，如login、logout、getOnlineUserInfo
+//   - data: 已编码的表单数据
+//   - cookie: HTTP Cookie，可为nil
+// 返回值: 响应模型和可能的错误
+func postEPortal(loginUrl string, method string, data string, cookie *http.Cookie) (*PortalResponse, error) {
+	// 构建接口URL
+	trueurl := strings.Split(loginUrl, "/eportal/")[0] + "/eportal/InterFace.do?method=" + method
+	// 使用共享的HTTP客户端
+	client := getHTTPClient()
+
 	// 创建POST请求
-	req, _ := http.NewRequest("POST", trueurl, &buf)
-	req.AddCookie(cookie)
+	req, err := http.NewRequest("POST", trueurl, strings.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
 
 	// 设置请求头
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
@@ -156,109 +181,141 @@ func login(loginUrl string, queryString string, account string, password string,
 
 	// 发送请求
 	resp, err := client.Do(req)
-
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
 	}
 	defer resp.Body.Close()
 	// 读取响应内容
-	body, _ := io.ReadAll(resp.Body)
-	return string(body), err
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%w: %s", ErrPortalServer, resp.Status)
+	}
+	return decodePortalResponse(string(body))
 }
 
 // RegisterMAC 注册MAC地址，仅在首次使用时需要
 // 参数:
 //   - loginUrl: 登录URL
 //   - userIndex: 用户索引
+//   - mac: MAC地址，为空时注册当前设备
 //   - cookie: HTTP Cookie
-// 返回值: 注册结果和可能的错误
-func RegisterMAC(loginUrl string, userIndex string, cookie *http.Cookie) (string, error) {
-	// 构建MAC地址注册URL
-	trueurl := strings.Split(loginUrl, "/eportal/")[0] + "/eportal/InterFace.do?method=registerMac"
-	// 使用共享的HTTP客户端
-	client := getHTTPClient()
-	
+// 返回值: 注册响应和可能的错误
+func RegisterMAC(loginUrl string, userIndex string, mac string, cookie *http.Cookie) (*PortalResponse, error) {
 	// 使用strings.Builder更高效地构建POST数据
 	var buf bytes.Buffer
 	buf.Grow(64) // 预分配缓冲区大小，减少内存重新分配
-	buf.WriteString("mac=&userIndex=")
+	buf.WriteString("mac=")
+	buf.WriteString(url.QueryEscape(mac))
+	buf.WriteString("&userIndex=")
 	buf.WriteString(url.QueryEscape(userIndex))
-	
-	// 创建POST请求
-	req, _ := http.NewRequest("POST", trueurl, &buf)
-	req.AddCookie(cookie)
 
-	// 设置请求头
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("User-Agent", GetUserAgent())
+	return postEPortal(loginUrl, "registerMac", buf.String(), cookie)
+}
 
-	// 发送请求
-	resp, err := client.Do(req)
+// LoginResult 认证结果
+type LoginResult struct {
+	Connected         bool            // 网络已连接，无需认证
+	KeepaliveInterval time.Duration   // 门户要求的保活间隔，0表示不需要保活
+	Response          *PortalResponse // 锐捷门户的登录响应，其他后端为nil
+	Register          *PortalResponse // MAC地址注册响应，未注册时为nil
+}
+
+// String 返回用于输出的认证结果信息
+// 网络已连接且不记录连接日志时返回空字符串
+func (r *LoginResult) String() string {
+	if r.Connected {
+		if logConnected {
+			return "The network is connected, no authentication required"
+		}
+		return ""
+	}
+	res := "Login success!"
+	if r.Register != nil {
+		res += " Register MAC: " + r.Register.String()
+	}
+	return res
+}
+
+// loginPortal 在门户上认证，门户属于其他后端时由该后端认证
+// 参数:
+//   - auth: 配置的认证后端
+//   - portal: 门户
+// 返回值: 认证结果和可能的错误
+func loginPortal(auth Authenticator, portal *Portal) (*LoginResult, error) {
+	backend, err := portalAuthenticator(auth, portal)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
-	// 读取响应内容
-	body, _ := io.ReadAll(resp.Body)
-	return string(body), nil
+	if backend != auth {
+		log.Println("The portal belongs to the " + backend.Name() + " backend, logging in with it")
+	}
+	return backend.Login(portal)
 }
 
 // Login 执行Hust网络认证
+// 使用配置的认证后端识别门户并认证
 // 返回值: 认证结果和可能的错误
-func Login() (res string, err error) {
-	// 获取登录URL
-	url, queryString, connected, err := GetLoginUrl()
+func Login() (*LoginResult, error) {
+	auth, err := getAuthenticator("")
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	// 检测网络连接状态，如果网络已连接，无需认证
+	connected, err := isConnected()
+	if err != nil {
+		return nil, err
 	}
-	// 如果网络已连接，根据配置决定是否输出信息
 	if connected {
-		if logConnected {
-			return "The network is connected, no authentication required", nil
-		}
-		return "", nil
+		return &LoginResult{Connected: true}, nil
 	}
+	return authenticate(auth)
+}
 
-	// 获取认证Cookie
-	cookie, err := GetCookie(url)
+// forceLogin 跳过网络连接检测直接认证
+// 用于保活检测到会话失效时，门户在会话失效后通常仍响应ping，连接检测会误判为已连接
+// 返回值: 认证结果和可能的错误
+func forceLogin() (*LoginResult, error) {
+	auth, err := getAuthenticator("")
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return authenticate(auth)
+}
+
+// authenticate 使用认证后端识别门户并认证
+// 参数: auth - 配置的认证后端
+// 返回值: 认证结果和可能的错误
+func authenticate(auth Authenticator) (*LoginResult, error) {
+	// 优先使用缓存的门户直接认证，跳过重定向页面
+	if portal := loadPortalCache(auth.Name()); portal != nil {
+		res, err := loginPortal(auth, portal)
+		if err == nil {
+			savePortalCache(auth.Name(), portal)
+			return res, nil
+		}
+		// 账号密码错误等与门户无关的错误不再重试，避免账号被锁定
+		if isCredentialError(err) || errors.Is(err, ErrCaptchaRequired) {
+			return nil, err
+		}
+		log.Println("Login with the cached portal failed, discovering the portal again: ", err)
+		clearPortalCache()
 	}
 
-	// 执行登录认证
-	login_res, err := login(url, queryString, account, password, serviceType, encrypt, cookie)
+	// 识别门户并认证
+	portal, err := discoverPortal(auth)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	
-	// 检查登录结果
-	if len(strings.Split(login_res, "\"result\":\"success\"")) == 2 {
-		res = "Login success!"
-	} else {
-		return "", errors.New("Login fail: " + login_res)
+	res, err := loginPortal(auth, portal)
+	if err != nil {
+		return nil, err
 	}
-
-	// 如果需要注册MAC地址
-	if register {
-		// 使用sync.Pool来复用JSON解析的缓冲区，减少内存分配
-		var resJson map[string]interface{}
-		loginResBytes := []byte(login_res) // 避免重复转换
-		err = json.Unmarshal(loginResBytes, &resJson)
-		if err == nil {
-			// 从响应中获取用户索引
-			if userIndex, ok := resJson["userIndex"].(string); ok {
-				// 注册MAC地址
-				res, err := RegisterMAC(url, userIndex, cookie)
-				if err != nil {
-					register = false
-					return "", err
-				}
-				return res, nil
-			}
-		}
-		// 如果不支持注册服务，重置注册标志
-		register = false
-		return "Unsupport register service. ", nil
+	// 不依赖重定向页面的后端无需缓存门户
+	if _, ok := auth.(Discoverer); !ok {
+		savePortalCache(auth.Name(), portal)
 	}
 	return res, nil
 }
@@ -0,0 +1,109 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 网络下线相关功能
+package cmd
+
+import (
+	"log"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+// logoutURL 下线时使用的登录URL，未指定时使用最近一次登录记录
+var logoutURL string
+
+// logoutCmd 表示下线命令
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Log out of the current web auth session",
+	Long: `Log out of the current web auth session.
+The userIndex of the last successful login is used, or looked up from the portal if it is unknown.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// 执行下线操作
+		res, err := Logout()
+		if err != nil {
+			fatal(err)
+		}
+		// 输出下线结果
+		log.Println(res)
+	},
+}
+
+// init 初始化logout命令
+func init() {
+	// 将logout命令添加到root命令下
+	rootCmd.AddCommand(logoutCmd)
+
+	// 添加loginUrl标志，用于在没有登录记录时指定认证页面
+	logoutCmd.Flags().StringVarP(&logoutURL, "loginUrl", "u", "", "登录URL (默认使用最近一次登录记录)")
+}
+
+// getOnlineUserIndex 从门户查询当前在线用户的用户索引
+// 参数: loginUrl - 登录URL
+// 返回值: 用户索引和可能的错误
+func getOnlineUserIndex(loginUrl string) (string, error) {
+	// userIndex为空时，门户根据请求的IP地址查询在线用户
+	info, err := getOnlineUserInfo(loginUrl, "")
+	if err != nil {
+		return "", err
+	}
+	return info.UserIndex, nil
+}
+
+// logout 执行网络下线
+// 参数:
+//   - loginUrl: 登录URL
+//   - userIndex: 用户索引
+// 返回值: 下线响应和可能的错误
+func logout(loginUrl string, userIndex string) (*PortalResponse, error) {
+	return postEPortal(loginUrl, "logout", "userIndex="+url.QueryEscape(userIndex), nil)
+}
+
+// resolveSession 获取会话
+// 参数: loginUrl - 指定的登录URL，为空时使用最近一次登录记录
+// 返回值: 会话和可能的错误
+func resolveSession(loginUrl string) (*portalSession, error) {
+	if loginUrl != "" {
+		return &portalSession{LoginURL: loginUrl}, nil
+	}
+	return getSession()
+}
+
+// Logout 执行Hust网络下线
+// 使用会话对应的认证后端下线
+// 返回值: 下线结果和可能的错误
+func Logout() (string, error) {
+	// 未指定登录URL时，使用最近一次登录记录
+	s, err := resolveSession(logoutURL)
+	if err != nil {
+		return "", err
+	}
+	auth, err := sessionAuthenticator(s)
+	if err != nil {
+		return "", err
+	}
+
+	// 执行下线
+	if _, err = auth.Logout(s); err != nil {
+		return "", err
+	}
+	clearSession()
+	return "Logout success!", nil
+}
+
+// logoutOnShutdown 在服务或守护进程停止时下线
+// 仅在启用logoutOnStop时执行
+func logoutOnShutdown() {
+	if !logoutOnStop {
+		return
+	}
+	res, err := Logout()
+	if err != nil {
+		log.Println("Logout failed, Err: ", err)
+		return
+	}
+	log.Println(res)
+}
@@ -0,0 +1,201 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 无感认证MAC地址管理相关功能
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// MAC地址管理相关变量
+var (
+	macURL     string // 使用的登录URL，未指定时使用最近一次登录记录
+	macAddress string // 要注册或取消的MAC地址
+)
+
+// 无感认证MAC地址管理命令定义
+var (
+	// macCmd 表示MAC地址管理命令
+	macCmd = &cobra.Command{
+		Use:   "mac",
+		Short: "Manage MAC addresses bound for seamless authentication",
+		Long:  `List, register and cancel the MAC addresses bound to the account for seamless authentication.`,
+	}
+
+	// macListCmd 列出已绑定的MAC地址
+	macListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the bound MAC addresses",
+		Run: func(cmd *cobra.Command, args []string) {
+			_, info, _, err := getMacSession()
+			if err != nil {
+				fatal(err)
+			}
+			bindings := parseMabInfo(info.MabInfo)
+			log.Printf("Bound MAC addresses: %d/%d", len(bindings), info.MabInfoMaxCount)
+			for _, b := range bindings {
+				log.Println(b)
+			}
+		},
+	}
+
+	// macRegisterCmd 注册MAC地址
+	macRegisterCmd = &cobra.Command{
+		Use:   "register",
+		Short: "Register a MAC address, the current device by default",
+		Run: func(cmd *cobra.Command, args []string) {
+			mac, err := normalizeMAC(macAddress)
+			if err != nil {
+				fatal(err)
+			}
+			loginUrl, info, cookie, err := getMacSession()
+			if err != nil {
+				fatal(err)
+			}
+			res, err := RegisterMAC(loginUrl, info.UserIndex, mac, cookie)
+			if err != nil {
+				fatal(err)
+			}
+			if !res.Success() {
+				fatal(classifyPortalResponse("Register MAC fail", res))
+			}
+			log.Println("Register MAC success!", res.Message)
+		},
+	}
+
+	// macCancelCmd 取消MAC地址绑定
+	macCancelCmd = &cobra.Command{
+		Use:   "cancel",
+		Short: "Cancel the binding of a MAC address",
+		Run: func(cmd *cobra.Command, args []string) {
+			mac, err := normalizeMAC(macAddress)
+			if err != nil {
+				fatal(err)
+			}
+			loginUrl, info, cookie, err := getMacSession()
+			if err != nil {
+				fatal(err)
+			}
+			// 未指定MAC地址时取消当前设备
+			if mac == "" {
+				if mac, err = normalizeMAC(info.UserMac); err != nil {
+					fatal(err)
+				}
+			}
+			if mac == "" {
+				fatal(errors.New("no MAC address to cancel, specify one with --mac"))
+			}
+			res, err := CancelMAC(loginUrl, info.UserID, mac, cookie)
+			if err != nil {
+				fatal(err)
+			}
+			if !res.Success() {
+				fatal(classifyPortalResponse("Cancel MAC fail", res))
+			}
+			log.Println("Cancel MAC success!", res.Message)
+		},
+	}
+)
+
+// init 初始化mac命令
+func init() {
+	rootCmd.AddCommand(macCmd)
+	macCmd.AddCommand(macListCmd, macRegisterCmd, macCancelCmd)
+
+	macCmd.PersistentFlags().StringVarP(&macURL, "loginUrl", "u", "", "登录URL (默认使用最近一次登录记录)")
+	macRegisterCmd.Flags().StringVarP(&macAddress, "mac", "m", "", "要注册的MAC地址，如 aa:bb:cc:dd:ee:ff (默认为当前设备)")
+	macCancelCmd.Flags().StringVarP(&macAddress, "mac", "m", "", "要取消的MAC地址，如 aa:bb:cc:dd:ee:ff (默认为当前设备)")
+}
+
+// CancelMAC 取消MAC地址的无感认证绑定
+// 参数:
+//   - loginUrl: 登录URL
+//   - userId: 认证账号
+//   - mac: MAC地址
+//   - cookie: HTTP Cookie
+// 返回值: 取消响应和可能的错误
+func CancelMAC(loginUrl string, userId string, mac string, cookie *http.Cookie) (*PortalResponse, error) {
+	data := "userId=" + url.QueryEscape(userId) + "&usermac=" + url.QueryEscape(mac)
+	return postEPortal(loginUrl, "cancelMacWithUserNameAndMac", data, cookie)
+}
+
+// getMacSession 获取MAC地址管理所需的登录URL、在线用户信息和Cookie
+// 返回值: 登录URL、在线用户信息、Cookie和可能的错误
+func getMacSession() (string, *PortalResponse, *http.Cookie, error) {
+	// 未指定登录URL时，使用最近一次登录记录
+	s, err := resolveSession(macURL)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	// 无感认证绑定使用锐捷门户的接口，早期的会话记录没有认证后端
+	if s.Backend != "" && s.Backend != "ruijie" {
+		return "", nil, nil, fmt.Errorf("MAC binding is only supported by the ruijie backend, the last session uses the %s backend", s.Backend)
+	}
+	info, err := lookupOnlineUserInfo(s.LoginURL, s.UserIndex)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	// 门户未返回当前设备的MAC地址时，使用登录时查询参数中的MAC地址
+	if info.UserMac == "" {
+		info.UserMac, _ = normalizeMAC(s.Query().UserMAC)
+	}
+	cookie, err := GetCookie(s.LoginURL)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return s.LoginURL, info, cookie, nil
+}
+
+// normalizeMAC 将MAC地址转换为门户使用的格式（12位小写十六进制，无分隔符）
+// 参数: mac - MAC地址，支持":"、"-"、"."分隔或无分隔符，为空时返回空字符串
+// 返回值: 转换后的MAC地址和可能的错误
+func normalizeMAC(mac string) (string, error) {
+	if mac == "" {
+		return "", nil
+	}
+	res := strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac))
+	if b, err := hex.DecodeString(res); err != nil || len(b) != 6 {
+		return "", errors.New("invalid MAC address: " + mac)
+	}
+	return res, nil
+}
+
+// parseMabInfo 解析无感认证绑定信息
+// 参数: mabInfo - JSON数组字符串
+// 返回值: 每个绑定的描述，MAC地址在前
+func parseMabInfo(mabInfo string) []string {
+	var items []map[string]interface{}
+	if mabInfo == "" || json.Unmarshal([]byte(mabInfo), &items) != nil {
+		return nil
+	}
+	res := make([]string, 0, len(items))
+	for _, item := range items {
+		keys := make([]string, 0, len(item))
+		for k := range item {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]string, 0, len(keys))
+		for _, k := range keys {
+			if strings.EqualFold(k, "mac") || strings.EqualFold(k, "userMac") {
+				fields = append([]string{fmt.Sprint(item[k])}, fields...)
+			} else {
+				fields = append(fields, fmt.Sprintf("%s=%v", k, item[k]))
+			}
+		}
+		res = append(res, strings.Join(fields, " "))
+	}
+	return res
+}
@@ -0,0 +1,133 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 在线会话查询相关功能
+package cmd
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// onlineURL 查询时使用的登录URL，未指定时使用最近一次登录记录
+var onlineURL string
+
+// onlineCmd 表示在线会话查询命令
+var onlineCmd = &cobra.Command{
+	Use:     "status",
+	Aliases: []string{"whoami"},
+	Short:   "Show the current online session",
+	Long: `Query the portal for the current online session,
+including the account, IP, MAC, service, online duration and used traffic,
+followed by the result of each connectivity detector.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// 查询在线会话信息
+		status, err := GetOnlineStatus()
+		if err != nil {
+			fatal(err)
+		}
+		// 输出在线会话信息
+		printOnlineStatus(status)
+		// 输出网络连接检测结果，包括各Ping目标的RTT和丢包率
+		printDetectResults()
+	},
+}
+
+// init 初始化status命令
+func init() {
+	// 将status命令添加到root命令下
+	rootCmd.AddCommand(onlineCmd)
+
+	// 添加loginUrl标志，用于在没有登录记录时指定认证页面
+	onlineCmd.Flags().StringVarP(&onlineURL, "loginUrl", "u", "", "登录URL (默认使用最近一次登录记录)")
+}
+
+// getOnlineUserInfo 从门户查询在线用户信息
+// 参数:
+//   - loginUrl: 登录URL
+//   - userIndex: 用户索引，为空时门户根据请求的IP地址查询
+// 返回值: 在线用户信息和可能的错误
+func getOnlineUserInfo(loginUrl string, userIndex string) (*PortalResponse, error) {
+	info, err := postEPortal(loginUrl, "getOnlineUserInfo", "userIndex="+url.QueryEscape(userIndex), nil)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Success() || info.UserIndex == "" {
+		return nil, errors.New("no online user found: " + info.String())
+	}
+	return info, nil
+}
+
+// lookupOnlineUserInfo 查询在线用户信息
+// 记录的用户索引失效时，根据IP地址重新查询
+// 参数:
+//   - loginUrl: 登录URL
+//   - userIndex: 记录的用户索引，可为空
+// 返回值: 在线用户信息和可能的错误
+func lookupOnlineUserInfo(loginUrl string, userIndex string) (*PortalResponse, error) {
+	info, err := getOnlineUserInfo(loginUrl, userIndex)
+	if err != nil && userIndex != "" {
+		info, err = getOnlineUserInfo(loginUrl, "")
+	}
+	return info, err
+}
+
+// GetOnlineStatus 查询当前在线会话
+// 使用会话对应的认证后端查询
+// 返回值: 在线会话信息和可能的错误
+func GetOnlineStatus() (*OnlineStatus, error) {
+	// 未指定登录URL时，使用最近一次登录记录
+	s, err := resolveSession(onlineURL)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := sessionAuthenticator(s)
+	if err != nil {
+		return nil, err
+	}
+	status, err := auth.Status(s)
+	if err != nil {
+		return nil, err
+	}
+	status.Query = s.Query()
+	return status, nil
+}
+
+// printOnlineStatus 输出在线会话信息
+func printOnlineStatus(status *OnlineStatus) {
+	log.Println("Account: ", status.Account)
+	if status.Name != "" {
+		log.Println("Name:    ", status.Name)
+	}
+	log.Println("IP:      ", status.IP)
+	log.Println("MAC:     ", status.MAC)
+	if status.Service != "" {
+		log.Println("Service: ", status.Service)
+	}
+	// 输出接入控制器信息
+	if q := status.Query; q != nil {
+		if q.AcName != "" || q.AcIP != "" {
+			log.Println("AC:      ", strings.TrimSpace(q.AcName+" "+q.AcIP))
+		}
+		if q.NasIP != "" {
+			log.Println("NAS IP:  ", q.NasIP)
+		}
+	}
+	// 输出在线时长、已用流量等统计信息
+	for _, item := range status.Items {
+		log.Println(item.Name+": ", item.Value)
+	}
+}
+
+// formatSeconds 将秒数格式化为时长字符串
+// 参数: seconds - 秒数
+// 返回值: 时长字符串，如1h0m0s
+func formatSeconds(seconds int64) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
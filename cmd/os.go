@@ -2,10 +2,16 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os/exec"
+	"sync"
+	"time"
 )
 
 // MaxCmdOutputSize 是执行的shell命令输出的最大长度（字节）
@@ -45,6 +51,162 @@ func RunCommand(command string, arguments ...string) (code int, output []byte, e
 	return cmd.ProcessState.ExitCode(), out, nil
 }
 
+// CommandProcess 通过标准输入输出按行交互的命令进程
+type CommandProcess struct {
+	cmd     *exec.Cmd
+	ctx     context.Context    // 命令的超时上下文
+	cancel  context.CancelFunc // 终止命令
+	timeout time.Duration      // 超时时间
+	stdin   io.WriteCloser     // 标准输入
+	lines   chan []byte        // 标准输出的各行，输出结束后关闭
+	readErr error              // 读取标准输出的错误，lines关闭后有效
+	stderr  *limitedWriter     // 标准错误输出
+
+	waitOnce sync.Once // 保证只等待一次命令退出
+	code     int       // 命令的退出码
+	waitErr  error     // 等待命令退出的错误
+}
+
+// StartCommand 启动shell命令，通过标准输入输出按行交互
+// 与RunCommand不同，命令运行期间即可逐行写入和读取
+// 参数:
+//   - timeout: 命令的最长运行时间，超时后命令被终止
+//   - command: 要执行的命令
+//   - arguments: 命令的参数列表
+// 返回值:
+//   - *CommandProcess: 命令进程，使用后应调用Close
+//   - error: 如果发生错误则返回错误信息
+func StartCommand(timeout time.Duration, command string, arguments ...string) (*CommandProcess, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	cmd := exec.CommandContext(ctx, command, arguments...)
+	// 子进程继承输出管道时，命令退出后不再无限等待
+	cmd.WaitDelay = time.Second
+	p := &CommandProcess{
+		cmd:     cmd,
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: timeout,
+		lines:   make(chan []byte),
+		stderr:  &limitedWriter{max: MaxCmdOutputSize},
+	}
+	cmd.Stderr = p.stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("command %q failed: %w", command, err)
+	}
+	p.stdin = stdin
+	go p.readLines(stdout)
+	return p, nil
+}
+
+// readLines 逐行读取标准输出，单行超过MaxCmdOutputSize时停止读取并丢弃剩余输出
+func (p *CommandProcess) readLines(r io.Reader) {
+	defer close(p.lines)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), MaxCmdOutputSize)
+	for scanner.Scan() {
+		line := append([]byte(nil), scanner.Bytes()...)
+		select {
+		case p.lines <- line:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+	if p.readErr = scanner.Err(); p.readErr != nil {
+		io.Copy(io.Discard, r)
+	}
+}
+
+// WriteLine 向标准输入写入一行
+// 参数: line - 不含换行符的内容
+// 返回值: 可能的错误
+func (p *CommandProcess) WriteLine(line []byte) error {
+	_, err := p.stdin.Write(append(line, '\n'))
+	return err
+}
+
+// ReadLine 读取标准输出的下一行
+// 返回值:
+//   - []byte: 不含换行符的内容
+//   - error: 输出结束时返回io.EOF，超时或输出过长时返回错误信息
+func (p *CommandProcess) ReadLine() ([]byte, error) {
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			if p.readErr != nil {
+				return nil, fmt.Errorf("command %q output: %w", p.cmd.Path, p.readErr)
+			}
+			return nil, io.EOF
+		}
+		return line, nil
+	case <-p.ctx.Done():
+		return nil, fmt.Errorf("command %q timed out after %s", p.cmd.Path, p.timeout)
+	}
+}
+
+// Wait 关闭标准输入并等待命令退出
+// 返回值:
+//   - int: 命令的退出码
+//   - []byte: 命令的标准错误输出（限制在MaxCmdOutputSize内）
+//   - error: 命令超时或无法等待时返回错误信息
+func (p *CommandProcess) Wait() (code int, stderr []byte, err error) {
+	p.waitOnce.Do(func() {
+		p.stdin.Close()
+		err := p.cmd.Wait()
+		if p.ctx.Err() == context.DeadlineExceeded {
+			p.code, p.waitErr = 1, fmt.Errorf("command %q timed out after %s", p.cmd.Path, p.timeout)
+		} else if eerr := new(exec.ExitError); errors.As(err, &eerr) {
+			p.code = eerr.ExitCode()
+		} else if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+			p.code, p.waitErr = 1, fmt.Errorf("command %q failed: %w", p.cmd.Path, err)
+		}
+		p.cancel()
+	})
+	return p.code, p.stderr.Bytes(), p.waitErr
+}
+
+// Close 终止命令并释放资源，命令已退出时无副作用
+func (p *CommandProcess) Close() error {
+	p.cancel()
+	_, _, err := p.Wait()
+	return err
+}
+
+// limitedWriter 最多保留max字节的输出，超出部分被丢弃
+type limitedWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+}
+
+// Write 写入输出，总是返回完整长度以免命令因写入失败退出
+func (w *limitedWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if n := w.max - w.buf.Len(); n > 0 {
+		w.buf.Write(b[:min(n, len(b))])
+	}
+	return len(b), nil
+}
+
+// Bytes 返回保留的输出
+func (w *limitedWriter) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return bytes.Clone(w.buf.Bytes())
+}
+
 // IsOpenWrt 检查当前主机操作系统是否为OpenWrt
 // 返回值:
 //   - bool: 如果是OpenWrt系统返回true，否则返回false
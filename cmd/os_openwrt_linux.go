@@ -52,7 +52,7 @@ func isOpenWrt() (ok bool) {
 		// 标记检测已完成
 		isOpenWrtChecked = true
 	})
-	
+
 	// 返回检测结果
 	return isOpenWrtValue
 }
@@ -0,0 +1,106 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 凭据错误暂停自动重试相关功能
+package cmd
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// resumeCmd 表示恢复自动认证命令
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume automatic login paused by credential errors",
+	Long: `Resume automatic login of the running cycle mode, daemon or service.
+Automatic login is paused when the portal reports a wrong password, a frozen account or no balance,
+to avoid the account being locked by repeated attempts.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// 创建恢复标记文件，由运行中的循环模式检测并删除
+		if err := os.WriteFile(getResumeFile(), nil, 0644); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Automatic login will resume in the next cycle.")
+	},
+}
+
+// init 初始化resume命令
+func init() {
+	// 将resume命令添加到root命令下
+	rootCmd.AddCommand(resumeCmd)
+}
+
+// getResumeFile 获取恢复标记文件路径
+func getResumeFile() string {
+	return filepath.Join(homeDir, "HustWebAuth.resume")
+}
+
+// isCredentialError 判断错误是否需要暂停自动重试
+// 账号或密码错误、账号欠费或被禁用时重试不会成功，反而可能导致账号被锁定；
+// 服务类型无效同样需要修改配置后才能恢复
+func isCredentialError(err error) bool {
+	return errors.Is(err, ErrWrongCredentials) || errors.Is(err, ErrAccountDisabled) || errors.Is(err, ErrInvalidService)
+}
+
+// getConfigModTime 获取配置文件的修改时间
+// 配置文件不存在时返回零值
+func getConfigModTime() time.Time {
+	fi, err := os.Stat(viper.ConfigFileUsed())
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// loginPause 循环模式的自动重试暂停状态
+type loginPause struct {
+	paused        bool      // 是否已暂停
+	configModTime time.Time // 暂停时配置文件的修改时间
+}
+
+// pause 暂停自动重试并输出一次告警
+func (p *loginPause) pause(err error) {
+	if p.paused {
+		return
+	}
+	p.paused = true
+	p.configModTime = getConfigModTime()
+	// 删除暂停前遗留的恢复标记
+	os.Remove(getResumeFile())
+	log.Println("ALERT: Login failed, Err: ", err)
+	log.Println("ALERT: Automatic login paused to avoid account lockout. Update the config file or run \"" + filenameWithSuffix + " resume\" to resume.")
+}
+
+// shouldResume 检查是否满足恢复自动重试的条件
+// 配置文件发生变化时重新读取配置，收到恢复命令时删除恢复标记
+func (p *loginPause) shouldResume() bool {
+	if !p.paused {
+		return true
+	}
+	if _, err := os.Stat(getResumeFile()); err == nil {
+		os.Remove(getResumeFile())
+		log.Println("Resume command received, automatic login resumed.")
+		p.paused = false
+		return true
+	}
+	if modTime := getConfigModTime(); !modTime.Equal(p.configModTime) {
+		if err := viper.ReadInConfig(); err != nil {
+			log.Println("Reload config failed, Err: ", err)
+			p.configModTime = modTime
+			return false
+		}
+		loadConfig()
+		log.Println("Config file changed, automatic login resumed.")
+		p.paused = false
+		return true
+	}
+	return false
+}
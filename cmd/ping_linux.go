@@ -0,0 +1,34 @@
+//go:build linux
+
+// Package cmd 提供Linux平台下非特权Ping的权限检查
+package cmd
+
+import (
+	"fmt"
+	"os"
+)
+
+// pingGroupRangeFile 允许创建ICMP数据报套接字的组ID范围
+const pingGroupRangeFile = "/proc/sys/net/ipv4/ping_group_range"
+
+// unprivilegedPingAllowed 检查当前进程能否发送非特权UDP ping
+// 当前进程的组ID或附加组ID在net.ipv4.ping_group_range范围内时允许
+// 返回值: 是否允许和说明原因的文本
+func unprivilegedPingAllowed() (bool, string) {
+	data, err := os.ReadFile(pingGroupRangeFile)
+	if err != nil {
+		return false, err.Error()
+	}
+	var low, high int
+	if _, err = fmt.Sscan(string(data), &low, &high); err != nil {
+		return false, "invalid " + pingGroupRangeFile + ": " + err.Error()
+	}
+	groups, _ := os.Getgroups()
+	groups = append(groups, os.Getgid())
+	for _, gid := range groups {
+		if gid >= low && gid <= high {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("net.ipv4.ping_group_range is %d %d", low, high)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+// Package cmd 提供非Linux平台下非特权Ping的权限检查
+package cmd
+
+import "runtime"
+
+// unprivilegedPingAllowed 检查当前平台能否发送非特权UDP ping
+// macOS默认允许，其他平台不支持
+// 返回值: 是否允许和说明原因的文本
+func unprivilegedPingAllowed() (bool, string) {
+	if runtime.GOOS == "darwin" {
+		return true, ""
+	}
+	return false, "unprivileged ping is not supported on " + runtime.GOOS
+}
@@ -0,0 +1,255 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 外部程序插件认证后端，通过标准输入输出交换JSON消息
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultPluginTimeout 插件处理一次请求的默认超时时间
+const defaultPluginTimeout = 30 * time.Second
+
+// pluginConfig 插件配置，对应配置项plugin
+type pluginConfig struct {
+	Command string        `mapstructure:"command"` // 插件可执行文件路径
+	Args    []string      `mapstructure:"args"`    // 插件参数
+	Timeout time.Duration `mapstructure:"timeout"` // 处理一次请求的超时时间
+}
+
+// pluginRequest 发送给插件的请求，每次调用启动一个插件进程，写入一行请求
+type pluginRequest struct {
+	Action      string            `json:"action"`                // 操作，选项: detect、login、logout、status
+	Account     string            `json:"account"`               // 认证账号
+	Password    string            `json:"password"`              // 认证密码
+	ServiceType string            `json:"serviceType"`           // 服务类型
+	RedirectURL string            `json:"redirectUrl"`           // 重定向URL
+	PageURL     string            `json:"pageUrl,omitempty"`     // 重定向页面跟随跳转后的URL，仅detect
+	Page        string            `json:"page,omitempty"`        // 重定向页面内容，仅detect
+	LoginURL    string            `json:"loginUrl,omitempty"`    // 登录URL
+	QueryString string            `json:"queryString,omitempty"` // 查询字符串
+	Extra       map[string]string `json:"extra,omitempty"`       // 登录时插件返回的会话信息
+}
+
+// pluginStatus 插件返回的在线会话信息
+type pluginStatus struct {
+	Account string       `json:"account"` // 认证账号
+	Name    string       `json:"name"`    // 用户姓名
+	IP      string       `json:"ip"`      // IP地址
+	MAC     string       `json:"mac"`     // MAC地址
+	Service string       `json:"service"` // 服务类型
+	Items   []pluginItem `json:"items"`   // 统计信息
+}
+
+// pluginItem 插件返回的统计信息项
+type pluginItem struct {
+	Name  string `json:"name"`  // 显示名称
+	Value string `json:"value"` // 数值
+}
+
+// pluginResponse 插件输出的一行消息
+// 包含log字段的消息作为日志输出，其他消息作为请求的结果
+type pluginResponse struct {
+	Log         string            `json:"log"`         // 日志
+	Error       string            `json:"error"`       // 错误信息，为空表示成功
+	Kind        string            `json:"kind"`        // 错误类型，为空时根据错误信息判断
+	Message     string            `json:"message"`     // 门户的提示信息
+	LoginURL    string            `json:"loginUrl"`    // 登录URL，仅detect
+	QueryString string            `json:"queryString"` // 查询字符串，仅detect
+	Extra       map[string]string `json:"extra"`       // 记录在会话中的信息，仅login
+	Status      *pluginStatus     `json:"status"`      // 在线会话信息，仅status
+}
+
+// pluginErrorKinds 插件返回的错误类型与错误的对应关系
+var pluginErrorKinds = map[string]error{
+	"network":     ErrNetworkUnreachable,
+	"redirect":    ErrRedirectNotFound,
+	"credentials": ErrWrongCredentials,
+	"disabled":    ErrAccountDisabled,
+	"captcha":     ErrCaptchaRequired,
+	"server":      ErrPortalServer,
+	"service":     ErrInvalidService,
+}
+
+// pluginAuthenticator 外部程序插件认证后端
+type pluginAuthenticator struct{}
+
+// init 注册插件认证后端
+func init() {
+	registerAuthenticator("plugin", func() Authenticator { return &pluginAuthenticator{} })
+}
+
+// Name 返回后端名称
+func (a *pluginAuthenticator) Name() string {
+	return "plugin"
+}
+
+// Detect 由插件从重定向页面识别门户
+// 插件未返回登录URL时使用跟随跳转后的最终URL
+func (a *pluginAuthenticator) Detect(page *LandingPage) (*Portal, error) {
+	res, err := callPlugin("Detect portal fail", &pluginRequest{Action: "detect", PageURL: page.URL, Page: page.Body})
+	if err != nil {
+		return nil, err
+	}
+	if res.LoginURL == "" {
+		res.LoginURL = page.URL
+	}
+	return &Portal{LoginURL: res.LoginURL, QueryString: res.QueryString}, nil
+}
+
+// Login 由插件完成认证
+// 插件返回的extra记录在会话中，供下线和查询时使用
+func (a *pluginAuthenticator) Login(portal *Portal) (*LoginResult, error) {
+	res, err := callPlugin("Login fail", &pluginRequest{Action: "login", LoginURL: portal.LoginURL, QueryString: portal.QueryString})
+	if err != nil {
+		return nil, err
+	}
+	if res.Message != "" {
+		log.Println(res.Message)
+	}
+
+	// 记录本次会话，供下线时使用
+	setSession(&portalSession{
+		Backend:     a.Name(),
+		LoginURL:    portal.LoginURL,
+		QueryString: portal.QueryString,
+		Account:     account,
+		Time:        time.Now(),
+		Extra:       res.Extra,
+	})
+	return &LoginResult{}, nil
+}
+
+// Logout 由插件下线会话
+func (a *pluginAuthenticator) Logout(session *portalSession) (string, error) {
+	res, err := callPlugin("Logout fail", sessionPluginRequest("logout", session))
+	if err != nil {
+		return "", err
+	}
+	return res.Message, nil
+}
+
+// Status 由插件查询在线会话
+func (a *pluginAuthenticator) Status(session *portalSession) (*OnlineStatus, error) {
+	res, err := callPlugin("Get online user info fail", sessionPluginRequest("status", session))
+	if err != nil {
+		return nil, err
+	}
+	if res.Status == nil {
+		return nil, errors.New("Get online user info fail: plugin returned no status")
+	}
+	status := &OnlineStatus{
+		Account: res.Status.Account,
+		Name:    res.Status.Name,
+		IP:      res.Status.IP,
+		MAC:     res.Status.MAC,
+		Service: res.Status.Service,
+	}
+	for _, item := range res.Status.Items {
+		status.Items = append(status.Items, statusItem{Name: item.Name, Value: item.Value})
+	}
+	return status, nil
+}
+
+// sessionPluginRequest 根据会话构建插件请求
+// 参数:
+//   - action: 操作
+//   - session: 门户会话
+// 返回值: 插件请求
+func sessionPluginRequest(action string, session *portalSession) *pluginRequest {
+	return &pluginRequest{
+		Action:      action,
+		LoginURL:    session.LoginURL,
+		QueryString: session.QueryString,
+		Extra:       session.Extra,
+	}
+}
+
+// getPluginConfig 读取配置项plugin中的插件配置
+func getPluginConfig() (*pluginConfig, error) {
+	conf := &pluginConfig{}
+	if err := viper.UnmarshalKey("plugin", conf); err != nil {
+		return nil, fmt.Errorf("invalid plugin config: %w", err)
+	}
+	if conf.Command == "" {
+		return nil, errors.New("plugin.command is not configured")
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaultPluginTimeout
+	}
+	return conf, nil
+}
+
+// callPlugin 启动插件进程，写入一行请求并读取结果
+// 插件在结果之前输出的日志消息直接输出到日志
+// 参数:
+//   - prefix: 错误信息前缀
+//   - req: 插件请求，账号密码等通用字段由此函数填写
+// 返回值: 插件返回的结果和可能的错误
+func callPlugin(prefix string, req *pluginRequest) (*pluginResponse, error) {
+	conf, err := getPluginConfig()
+	if err != nil {
+		return nil, err
+	}
+	req.Account = account
+	req.Password = password
+	req.ServiceType = serviceType
+	req.RedirectURL = redirectURL
+	line, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := StartCommand(conf.Timeout, conf.Command, conf.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+	if err = p.WriteLine(line); err != nil {
+		return nil, fmt.Errorf("%s: write plugin request: %w", prefix, err)
+	}
+
+	for {
+		out, err := p.ReadLine()
+		if errors.Is(err, io.EOF) {
+			// 插件未输出结果就退出，使用退出码和标准错误输出作为错误信息
+			code, stderr, err := p.Wait()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", prefix, err)
+			}
+			return nil, fmt.Errorf("%s: plugin exited with code %d without a result: %s", prefix, code, strings.TrimSpace(string(stderr)))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", prefix, err)
+		}
+		if len(strings.TrimSpace(string(out))) == 0 {
+			continue
+		}
+
+		res := &pluginResponse{}
+		if err = json.Unmarshal(out, res); err != nil {
+			return nil, fmt.Errorf("%s: invalid plugin output %q: %w", prefix, out, err)
+		}
+		if res.Log != "" {
+			log.Println("Plugin:", res.Log)
+			continue
+		}
+		if res.Error == "" {
+			return res, nil
+		}
+		if kind, ok := pluginErrorKinds[strings.ToLower(res.Kind)]; ok {
+			return nil, fmt.Errorf("%s: %w: %s", prefix, kind, res.Error)
+		}
+		return nil, classifyMessage(prefix, res.Error)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 门户查询参数相关功能
+package cmd
+
+import (
+	urlutil "net/url"
+	"sort"
+	"strings"
+)
+
+// PortalQuery 门户重定向地址中的查询参数，由接入控制器附加
+type PortalQuery struct {
+	UserIP    string // 用户IP地址
+	UserMAC   string // 用户MAC地址
+	AcName    string // 接入控制器名称
+	AcIP      string // 接入控制器IP地址
+	NasIP     string // NAS IP地址
+	NasID     string // NAS标识
+	NasPortID string // NAS端口标识
+	SSID      string // 无线网络名称，有线接入时为空
+	APMAC     string // 无线接入点MAC地址
+	VLAN      string // VLAN编号
+	Port      string // 交换机端口
+	URL       string // 用户原本访问的URL
+
+	Values urlutil.Values // 全部查询参数，包括未识别的参数
+}
+
+// portalQueryField 查询参数字段及其在不同门户中使用的参数名
+type portalQueryField struct {
+	name  string   // 显示名称
+	keys  []string // 参数名，按优先级排列
+	value *string  // 字段
+}
+
+// fields 返回查询参数字段，顺序即为输出顺序
+func (q *PortalQuery) fields() []portalQueryField {
+	return []portalQueryField{
+		{"wlanuserip", []string{"wlanuserip", "userip", "user_ip", "ip"}, &q.UserIP},
+		{"mac", []string{"mac", "wlanusermac", "usermac", "user_mac"}, &q.UserMAC},
+		{"wlanacname", []string{"wlanacname", "acname", "ac_name"}, &q.AcName},
+		{"wlanacip", []string{"wlanacip", "acip", "ac_ip"}, &q.AcIP},
+		{"nasip", []string{"nasip", "nas_ip"}, &q.NasIP},
+		{"nasid", []string{"nasid", "nas_id"}, &q.NasID},
+		{"nasportid", []string{"nasportid"}, &q.NasPortID},
+		{"ssid", []string{"ssid"}, &q.SSID},
+		{"apmac", []string{"apmac", "ap_mac"}, &q.APMAC},
+		{"vid", []string{"vid", "vlan"}, &q.VLAN},
+		{"port", []string{"port"}, &q.Port},
+		{"url", []string{"url", "redirect_url"}, &q.URL},
+	}
+}
+
+// parsePortalQuery 解析门户查询字符串
+// 锐捷后端的查询字符串经过URL编码，其他后端为原始查询字符串，两种形式均可解析
+// 参数: queryString - 查询字符串
+// 返回值: 查询参数，无法解析的部分被忽略
+func parsePortalQuery(queryString string) *PortalQuery {
+	raw := queryString
+	if !strings.Contains(raw, "=") {
+		if s, err := urlutil.QueryUnescape(raw); err == nil {
+			raw = s
+		}
+	}
+	values, _ := urlutil.ParseQuery(raw)
+	q := &PortalQuery{Values: values}
+	for _, f := range q.fields() {
+		for _, key := range f.keys {
+			if v := values.Get(key); v != "" {
+				*f.value = v
+				break
+			}
+		}
+	}
+	return q
+}
+
+// Items 返回用于输出的查询参数
+// 已识别的参数按固定顺序在前，其余参数按名称排序在后，空值被忽略
+func (q *PortalQuery) Items() []statusItem {
+	var items []statusItem
+	known := map[string]bool{}
+	for _, f := range q.fields() {
+		for _, key := range f.keys {
+			known[key] = true
+		}
+		if *f.value != "" {
+			items = append(items, statusItem{Name: f.name, Value: *f.value})
+		}
+	}
+
+	names := make([]string, 0, len(q.Values))
+	for name := range q.Values {
+		if !known[name] && q.Values.Get(name) != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		items = append(items, statusItem{Name: name, Value: q.Values.Get(name)})
+	}
+	return items
+}
+
+// Query 解析门户的查询参数
+func (p *Portal) Query() *PortalQuery {
+	return parsePortalQuery(p.QueryString)
+}
+
+// Query 解析会话的查询参数
+func (s *portalSession) Query() *PortalQuery {
+	return parsePortalQuery(s.QueryString)
+}
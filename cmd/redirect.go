@@ -0,0 +1,155 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 门户重定向页面解析相关功能
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	urlutil "net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// 门户跳转地址在重定向页面中的形式
+const (
+	redirectFormLocation = "http-location" // HTTP 3xx响应的Location头
+	redirectFormMeta     = "meta-refresh"  // <meta http-equiv="refresh">标签
+	redirectFormScript   = "script"        // 脚本中的location.href、location.replace等
+	redirectFormQuoted   = "quoted-url"    // 页面中第一个带引号的URL
+	redirectFormDirect   = "direct"        // 根据配置构建的跳转地址
+	redirectFormCache    = "cache"         // 上次认证成功时缓存的门户
+)
+
+// 重定向页面解析相关正则表达式
+var (
+	// redirectHTMLCommentRegexp 匹配HTML注释
+	redirectHTMLCommentRegexp = regexp.MustCompile(`(?s)<!--.*?-->`)
+	// redirectJSCommentRegexp 匹配脚本中的块注释和整行注释
+	redirectJSCommentRegexp = regexp.MustCompile(`(?s:/\*.*?\*/)|(?m:^[ \t]*//[^\n]*)`)
+	// redirectMetaRegexp 匹配meta refresh标签
+	redirectMetaRegexp = regexp.MustCompile(`(?i)<meta\s[^>]*http-equiv\s*=\s*["']?refresh["']?[^>]*>`)
+	// redirectContentRegexp 匹配meta标签的content属性
+	redirectContentRegexp = regexp.MustCompile(`(?i)content\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	// redirectRefreshURLRegexp 匹配refresh内容中的URL，如0;url=http://...
+	redirectRefreshURLRegexp = regexp.MustCompile(`(?i)^\s*\d*\s*[;,]?\s*url\s*=\s*['"]?([^'"]+)['"]?\s*$`)
+	// redirectScriptRegexp 匹配脚本中的跳转，如top.self.location.href='...'、location.replace("...")
+	redirectScriptRegexp = regexp.MustCompile(`(?i)\blocation(?:\.href)?\s*=\s*(?:"([^"]+)"|'([^']+)')|\blocation\.(?:replace|assign)\(\s*(?:"([^"]+)"|'([^']+)')`)
+	// redirectQuotedRegexp 匹配带引号的绝对URL
+	redirectQuotedRegexp = regexp.MustCompile(`"(https?://[^"\s]+)"|'(https?://[^'\s]+)'`)
+)
+
+// portalRedirect 从重定向页面中解析出的门户跳转地址
+type portalRedirect struct {
+	URL  string // 门户跳转地址，相对地址已根据页面URL解析
+	Form string // 跳转地址的形式
+}
+
+// parseRedirect 从重定向页面中解析门户跳转地址
+// 直连认证直接使用构建的跳转地址；HTTP跳转已带有门户查询参数时直接使用，
+// 避免最终落在门户页面时误用其中的脚本跳转；否则依次识别最终页面中的meta refresh标签和脚本跳转、HTTP跳转和页面中带引号的URL，
+// 忽略HTML和脚本注释中的内容
+// 参数: page - 重定向页面
+// 返回值: 门户跳转地址和可能的错误
+func parseRedirect(page *LandingPage) (*portalRedirect, error) {
+	if page.Direct && page.Location != "" {
+		return resolveRedirect(page.URL, page.Location, redirectFormDirect)
+	}
+	if page.Location != "" && hasPortalParams(page.Location) {
+		return resolveRedirect(page.URL, page.Location, redirectFormLocation)
+	}
+
+	body := redirectHTMLCommentRegexp.ReplaceAllString(page.Body, "")
+	body = redirectJSCommentRegexp.ReplaceAllString(body, "")
+
+	if tag := redirectMetaRegexp.FindString(body); tag != "" {
+		if match := redirectContentRegexp.FindStringSubmatch(tag); match != nil {
+			content := html.UnescapeString(match[1] + match[2])
+			if m := redirectRefreshURLRegexp.FindStringSubmatch(content); m != nil {
+				return resolveRedirect(page.URL, m[1], redirectFormMeta)
+			}
+		}
+	}
+	if match := redirectScriptRegexp.FindStringSubmatch(body); match != nil {
+		return resolveRedirect(page.URL, strings.Join(match[1:], ""), redirectFormScript)
+	}
+	if page.Location != "" {
+		return resolveRedirect(page.URL, page.Location, redirectFormLocation)
+	}
+	if match := redirectQuotedRegexp.FindStringSubmatch(body); match != nil {
+		return resolveRedirect(page.URL, html.UnescapeString(match[1]+match[2]), redirectFormQuoted)
+	}
+	return nil, fmt.Errorf("%w: no redirect found in the landing page", ErrRedirectNotFound)
+}
+
+// hasPortalParams 判断跳转地址是否已带有门户查询参数（wlanuserip或nasip）
+// 参数: ref - 跳转地址
+// 返回值: 是否带有门户查询参数
+func hasPortalParams(ref string) bool {
+	u, err := urlutil.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return false
+	}
+	values := u.Query()
+	return values.Get("wlanuserip") != "" || values.Get("nasip") != ""
+}
+
+// resolveRedirect 根据页面URL解析相对的跳转地址
+// 参数:
+//   - base: 页面URL，为空时不解析
+//   - ref: 跳转地址
+//   - form: 跳转地址的形式
+// 返回值: 门户跳转地址和可能的错误
+func resolveRedirect(base string, ref string, form string) (*portalRedirect, error) {
+	ref = strings.TrimSpace(ref)
+	u, err := urlutil.Parse(base)
+	if err != nil || base == "" {
+		return &portalRedirect{URL: ref, Form: form}, nil
+	}
+	target, err := u.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s redirect %q: %w", ErrRedirectNotFound, form, ref, err)
+	}
+	return &portalRedirect{URL: target.String(), Form: form}, nil
+}
+
+// readLandingPage 从文件读取保存的重定向页面，用于离线识别门户
+// 文件以"HTTP/"开头时按HTTP响应解析（如curl -i的输出），否则作为页面内容
+// 参数:
+//   - path: 文件路径
+//   - pageUrl: 页面URL，用于解析相对跳转地址
+// 返回值: 重定向页面和可能的错误
+func readLandingPage(path string, pageUrl string) (*LandingPage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	page := &LandingPage{URL: pageUrl, Body: string(data)}
+	if !strings.HasPrefix(page.Body, "HTTP/") {
+		return page, nil
+	}
+
+	// 统一换行符，兼容手工保存的响应
+	raw := strings.ReplaceAll(page.Body, "\r\n", "\n")
+	raw = strings.ReplaceAll(raw, "\n", "\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("parse saved response %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil && len(body) == 0 {
+		return nil, fmt.Errorf("parse saved response %s: %w", path, err)
+	}
+	page.Body = string(body)
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		page.Location = resp.Header.Get("Location")
+	}
+	return page, nil
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// landingPortalQuery 样本页面中门户跳转地址的查询参数
+const landingPortalQuery = "?wlanuserip=10.12.34.56&wlanacname=HUST_AC_01&ssid=&nasip=172.18.18.1&snmpagentip=&mac=4c5e0c1a2b3d" +
+	"&t=wireless-v2&url=http%3A%2F%2F123.123.123.123%2F&apmac=&nasid=HUST_AC_01&vid=1020&port=14" +
+	"&nasportid=AggregatePort%201.10200000%3A1020-0"
+
+func TestParseRedirectFixtures(t *testing.T) {
+	tests := map[string]portalRedirect{
+		"http-302.http": {
+			URL:  "http://172.18.18.60:8080/eportal/index.jsp" + landingPortalQuery,
+			Form: redirectFormLocation,
+		},
+		"http-302-portal-script.http": {
+			URL:  "http://172.18.18.60:8080/eportal/index.jsp" + landingPortalQuery,
+			Form: redirectFormLocation,
+		},
+		"link.html": {
+			URL:  "http://172.18.18.60:8080/eportal/index.jsp?wlanuserip=10.12.34.56&wlanacname=HUST_AC_01&nasip=172.18.18.1&mac=4c5e0c1a2b3d",
+			Form: redirectFormQuoted,
+		},
+		"location-replace.html": {
+			URL:  "http://123.123.123.123/eportal/index.jsp" + landingPortalQuery,
+			Form: redirectFormScript,
+		},
+		"meta-refresh.html": {
+			URL:  "http://172.18.18.60:8080/eportal/index.jsp" + landingPortalQuery,
+			Form: redirectFormMeta,
+		},
+		"ruijie-comment.html": {
+			URL:  "http://172.18.18.60:8080/eportal/index.jsp" + landingPortalQuery,
+			Form: redirectFormScript,
+		},
+		"ruijie-double-quote.html": {
+			URL:  "http://172.18.18.60:8080/eportal/index.jsp" + landingPortalQuery,
+			Form: redirectFormScript,
+		},
+		"ruijie-single-quote.html": {
+			URL:  "http://172.18.18.60:8080/eportal/index.jsp" + landingPortalQuery,
+			Form: redirectFormScript,
+		},
+	}
+
+	// 每个样本都需要有预期结果，新增样本时同时补充
+	entries, err := os.ReadDir(filepath.Join("testdata", "landing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if _, ok := tests[entry.Name()]; !ok {
+			t.Errorf("no expected redirect for fixture %s", entry.Name())
+		}
+	}
+
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			page, err := readLandingPage(filepath.Join("testdata", "landing", name), "http://123.123.123.123")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseRedirect(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != want {
+				t.Errorf("parseRedirect() = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestParseRedirectOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		page LandingPage
+		want portalRedirect
+	}{
+		{
+			name: "direct",
+			page: LandingPage{URL: "http://172.18.18.60:8080/eportal/index.jsp?a=1", Location: "http://172.18.18.60:8080/eportal/index.jsp?a=1", Direct: true},
+			want: portalRedirect{URL: "http://172.18.18.60:8080/eportal/index.jsp?a=1", Form: redirectFormDirect},
+		},
+		{
+			name: "final body before http redirect",
+			page: LandingPage{
+				URL:      "http://gateway/auth?ip=1",
+				Location: "http://gateway/auth?ip=1",
+				Body:     "<script>location.href='http://172.18.18.60:8080/eportal/index.jsp?a=1'</script>",
+			},
+			want: portalRedirect{URL: "http://172.18.18.60:8080/eportal/index.jsp?a=1", Form: redirectFormScript},
+		},
+		{
+			name: "http redirect before quoted url",
+			page: LandingPage{
+				URL:      "http://172.18.18.60:8080/eportal/index.jsp?a=1",
+				Location: "http://172.18.18.60:8080/eportal/index.jsp?a=1",
+				Body:     `<a href="http://www.hust.edu.cn/">华中科技大学</a>`,
+			},
+			want: portalRedirect{URL: "http://172.18.18.60:8080/eportal/index.jsp?a=1", Form: redirectFormLocation},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRedirect(&tt.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Errorf("parseRedirect() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
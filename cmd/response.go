@@ -0,0 +1,117 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// ePortal响应模型相关功能
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// flexInt 兼容门户以数字或字符串返回的整数字段
+type flexInt int
+
+// UnmarshalJSON 实现json.Unmarshaler接口
+func (i *flexInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), "\"")
+	if s == "" || s == "null" {
+		*i = 0
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*i = flexInt(v)
+	return nil
+}
+
+// ballItem 门户返回的统计信息项，如在线时长、已用流量
+type ballItem struct {
+	DisplayName string `json:"displayName"` // 显示名称
+	ID          string `json:"id"`          // 标识
+	Type        string `json:"type"`        // 类型，如time、flow
+	Value       string `json:"value"`       // 数值
+}
+
+// PortalResponse ePortal InterFace.do接口的响应
+// 所有接口共用同一模型，未返回的字段保持零值
+type PortalResponse struct {
+	// 通用字段
+	Result            string  `json:"result"`            // 请求结果，成功时为success
+	Message           string  `json:"message"`           // 提示信息
+	UserIndex         string  `json:"userIndex"`         // 用户索引
+	ValidCodeURL      string  `json:"validCodeUrl"`      // 验证码地址，非空时需要输入验证码
+	KeepaliveInterval flexInt `json:"keepaliveInterval"` // 保活间隔，单位秒
+
+	// 在线用户信息，由getOnlineUserInfo返回
+	UserID          string       `json:"userId"`          // 认证账号
+	UserName        string       `json:"userName"`        // 用户姓名
+	UserIP          string       `json:"userIp"`          // 用户IP地址
+	UserMac         string       `json:"userMac"`         // 用户MAC地址
+	Service         serviceField `json:"service"`         // 当前服务类型，pageInfo返回可选服务列表
+	RealServiceName string       `json:"realServiceName"` // 服务显示名称
+	BallInfo        string       `json:"ballInfo"`        // 统计信息，JSON数组字符串
+	MabInfo         string       `json:"mabInfo"`         // 无感认证绑定的MAC地址，JSON数组字符串
+	MabInfoMaxCount flexInt      `json:"mabInfoMaxCount"` // 无感认证可绑定的MAC地址数量上限
+
+	// 页面信息，由pageInfo返回，可选服务列表位于Service字段
+	PublicKeyExponent string `json:"publicKeyExponent"` // RSA公钥指数，十六进制
+	PublicKeyModulus  string `json:"publicKeyModulus"`  // RSA公钥模数，十六进制
+	PasswordEncrypt   string `json:"passwordEncrypt"`   // 门户是否要求加密密码
+
+	Raw string `json:"-"` // 原始响应内容
+}
+
+// decodePortalResponse 解析ePortal响应
+// 参数: body - 响应内容
+// 返回值: 响应模型和可能的错误
+func decodePortalResponse(body string) (*PortalResponse, error) {
+	res := &PortalResponse{Raw: body}
+	if err := json.Unmarshal([]byte(body), res); err != nil {
+		return nil, fmt.Errorf("%w: invalid portal response: %s", ErrPortalServer, body)
+	}
+	return res, nil
+}
+
+// Success 判断请求是否成功
+func (r *PortalResponse) Success() bool {
+	return r.Result == "success"
+}
+
+// String 返回门户提示信息，没有提示信息时返回原始响应内容
+func (r *PortalResponse) String() string {
+	if r.Message != "" {
+		return r.Message
+	}
+	return r.Raw
+}
+
+// balls 解析统计信息
+// 返回值: 统计信息项列表，解析失败时返回nil
+func (r *PortalResponse) balls() []ballItem {
+	var items []ballItem
+	if r.BallInfo == "" || json.Unmarshal([]byte(r.BallInfo), &items) != nil {
+		return nil
+	}
+	return items
+}
+
+// trimJSONP 去除JSONP响应的回调包装
+// 参数: body - 响应内容，如callback({...})
+// 返回值: JSON内容，非JSONP响应时原样返回
+func trimJSONP(body string) string {
+	body = strings.TrimSpace(body)
+	if strings.HasPrefix(body, "{") {
+		return body
+	}
+	start, end := strings.Index(body, "("), strings.LastIndex(body, ")")
+	if start < 0 || end < start {
+		return body
+	}
+	return body[start+1 : end]
+}
@@ -27,10 +27,12 @@ import (
 	"io/fs"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	daemon "github.com/sevlyar/go-daemon"
@@ -40,47 +42,50 @@ import (
 
 var (
 	// 配置相关变量
-	cfgFile       string   // 配置文件路径
-	account       string   // 认证账号
-	password      string   // 认证密码
-	serviceType   string   // 服务类型
-	encrypt       bool     // 密码是否加密
-	saveCfg       bool     // 是否保存配置
-	
+	cfgFile      string // 配置文件路径
+	account      string // 认证账号
+	password     string // 认证密码
+	serviceType  string // 服务类型
+	encrypt      bool   // 是否使用门户公钥加密密码
+	saveCfg      bool   // 是否保存配置
+	logoutOnStop bool   // 服务或守护进程停止时是否下线
+
 	// User-Agent相关变量
-	userAgent     string   // 自定义User-Agent
-	
+	userAgent string // 自定义User-Agent
+
 	// Ping相关变量
-	pingIP        string   // Ping的目标IP地址
-	pingCount     int      // Ping次数
-	pingTimeout   time.Duration // Ping超时时间
-	pingPrivilege bool     // 是否使用特权Ping
-	
+	pingIPs         []string      // Ping的目标IP地址列表
+	pingQuorum      int           // 判定已连接需要可达的目标数量
+	pingCount       int           // Ping次数
+	pingTimeout     time.Duration // Ping超时时间
+	pingPrivilege   bool          // 是否使用特权Ping
+	pingFallbackURL string        // 无权发送ICMP时改用HTTP检测的地址，为空表示不回退
+
 	// 重定向和日志相关变量
-	redirectURL   string   // 重定向URL
-	logDir        string   // 日志目录
-	logFile       string   // 日志文件名
-	logRandom     bool     // 日志文件名是否包含随机字符串
-	logAppend     bool     // 日志文件是否追加模式
-	logConnected  bool     // 是否记录网络连接日志
-	sysLog        bool     // 是否启用系统日志
-	
+	redirectURL  string // 重定向URL
+	logDir       string // 日志目录
+	logFile      string // 日志文件名
+	logRandom    bool   // 日志文件名是否包含随机字符串
+	logAppend    bool   // 日志文件是否追加模式
+	logConnected bool   // 是否记录网络连接日志
+	sysLog       bool   // 是否启用系统日志
+
 	// 守护进程相关变量
-	daemonEnable  bool     // 是否启用守护进程模式
-	daemonPidFile string   // 守护进程PID文件路径
-	
+	daemonEnable  bool   // 是否启用守护进程模式
+	daemonPidFile string // 守护进程PID文件路径
+
 	// 循环模式相关变量
-	cycleEnable   bool     // 是否启用循环模式
+	cycleEnable   bool          // 是否启用循环模式
 	cycleDuration time.Duration // 循环间隔时间
-	cycleRetry    int      // 循环重试次数
+	cycleRetry    int           // 循环重试次数
 )
 
 // 全局变量
-var execPath = getCurrentAbPath()               // 当前可执行文件绝对路径
+var execPath = getCurrentAbPath()                    // 当前可执行文件绝对路径
 var _, filenameWithSuffix = filepath.Split(execPath) // 可执行文件名（含后缀）
-var sysType = runtime.GOOS                      // 操作系统类型
-var homeDir string                              // 用户主目录
-var homeError error                             // 获取用户主目录时的错误
+var sysType = runtime.GOOS                           // 操作系统类型
+var homeDir string                                   // 用户主目录
+var homeError error                                  // 获取用户主目录时的错误
 
 // rootCmd 表示不带任何子命令时的基础命令
 var rootCmd = &cobra.Command{
@@ -99,7 +104,7 @@ func runDaemon() {
 	if saveCfg {
 		return
 	}
-	
+
 	// 非Windows系统且启用守护进程模式
 	if sysType != "windows" && daemonEnable {
 		// 如果未指定日志文件，使用默认路径
@@ -110,12 +115,12 @@ func runDaemon() {
 			}
 			logFile = filepath.Join(tmpDir, filenameWithSuffix+".log")
 		}
-		
+
 		// 如果未指定PID文件，使用默认路径
 		if daemonPidFile == "" {
 			daemonPidFile = "/var/run/" + filenameWithSuffix + "_daemon.pid"
 		}
-		
+
 		// 创建守护进程上下文
 		cntxt := &daemon.Context{
 			PidFileName: daemonPidFile,
@@ -139,6 +144,17 @@ func runDaemon() {
 
 		log.Println("- - - - - - - - - - - - - - - - - - -")
 		log.Println("HustWebAuth Daemon started.")
+
+		// 收到停止信号时，按配置下线后退出
+		go func() {
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			<-sigs
+			logoutOnShutdown()
+			cntxt.Release()
+			log.Println("HustWebAuth Daemon stopped.")
+			os.Exit(0)
+		}()
 	}
 
 	// 运行循环模式或单次认证
@@ -150,12 +166,16 @@ func runCycle() {
 	log.Println("- - - - - - - - - - - - - - - - - - -")
 	log.Println("HustWebAuth started.")
 	retryCount := 0
-	
+	// 凭据错误时暂停自动重试，避免账号被锁定
+	pause := &loginPause{}
+
 	// 执行首次登录
 	res, err := Login()
 	if err != nil {
 		if cycleEnable {
-			if cycleRetry < 0 {
+			if isCredentialError(err) {
+				pause.pause(err)
+			} else if cycleRetry < 0 {
 				log.Println("Login failed, Err: ", err)
 				log.Println("Login retrying...")
 			} else if retryCount < cycleRetry {
@@ -163,13 +183,13 @@ func runCycle() {
 				log.Println("Login failed, Err: ", err)
 				log.Println("Login retry ", strconv.Itoa(retryCount), "times after "+cycleDuration.String())
 			} else {
-				log.Fatal("Login failed, Err: ", err)
+				fatal(err, "Login failed, Err: ", err)
 			}
 		} else {
-			log.Fatal("Login failed, Err: ", err)
+			fatal(err, "Login failed, Err: ", err)
 		}
 	}
-	if res != "" {
+	if res != nil && res.String() != "" {
 		log.Println(res)
 	}
 
@@ -177,39 +197,53 @@ func runCycle() {
 	if cycleEnable {
 		eventsTick := time.NewTicker(cycleDuration)
 		defer eventsTick.Stop()
-		
+
+		// 登录成功后按门户下发的间隔保活
+		ka := newKeepaliveLoop()
+		ka.restart(res)
+		defer ka.close()
+
 		// 使用通道来控制并发，避免资源竞争
-		loginChan := make(chan struct{}, 1) // 缓冲通道，防止阻塞
+		loginChan := make(chan bool, 1) // 缓冲通道，防止阻塞，值表示是否跳过连接检测
 		resultChan := make(chan loginResult, 1)
-		
-		// 初始触发一次登录
-		loginChan <- struct{}{}
-		
+
+		// 初始触发一次登录，已暂停时等待恢复
+		if !pause.paused {
+			loginChan <- false
+		}
+
 		// 启动一个goroutine处理登录请求
 		go func() {
-			for range loginChan {
-				res, err := Login()
+			for force := range loginChan {
+				res, err := cycleLogin(force)
 				resultChan <- loginResult{res: res, err: err}
 			}
 		}()
-		
+
 		// 主循环，处理定时器和登录结果
 		for {
 			select {
 			case <-eventsTick.C:
-				// 定时触发登录请求
-				select {
-				case loginChan <- struct{}{}:
-					// 成功发送登录请求
-				default:
+				// 已暂停且未满足恢复条件时，跳过这次
+				if !pause.shouldResume() {
+					continue
+				}
+				// 定时触发登录请求，会话失效后持续强制认证直至成功
+				if !requestLogin(loginChan, ka.sessionLost) {
 					// 上一次登录还在处理中，跳过这次
 					log.Println("Previous login still in progress, skipping this cycle")
 				}
-				
+
+			case err := <-ka.lost:
+				handleSessionLost(ka, err, pause, loginChan)
+
 			case result := <-resultChan:
 				// 处理登录结果
 				if result.err != nil {
-					if cycleRetry < 0 {
+					if isCredentialError(result.err) {
+						pause.pause(result.err)
+						retryCount = 0
+					} else if cycleRetry < 0 {
 						log.Println("Login failed, Err: ", result.err)
 						log.Println("Login retrying...")
 					} else if retryCount < cycleRetry {
@@ -218,12 +252,13 @@ func runCycle() {
 						log.Println("Login retry", strconv.Itoa(retryCount), "times after", cycleDuration.String())
 					} else {
 						log.Println("Login failed, Err: ", result.err)
-						log.Fatal("Exceed the maximum number of retries, daemon stopped!")
+						fatal(result.err, "Exceed the maximum number of retries, daemon stopped!")
 					}
 				} else {
-					if result.res != "" {
-						log.Println(result.res)
+					if msg := result.res.String(); msg != "" {
+						log.Println(msg)
 					}
+					ka.restart(result.res)
 					retryCount = 0
 				}
 			}
@@ -231,10 +266,62 @@ func runCycle() {
 	}
 }
 
+// cycleLogin 循环模式下执行一次登录
+// 参数: force - 是否跳过网络连接检测
+// 返回值: 认证结果和可能的错误
+func cycleLogin(force bool) (*LoginResult, error) {
+	if force {
+		return forceLogin()
+	}
+	return Login()
+}
+
+// requestLogin 发送登录请求，不阻塞
+// 强制认证会替换尚未处理的普通登录请求
+// 参数:
+//   - loginChan: 登录请求通道
+//   - force: 是否跳过网络连接检测
+// 返回值: 是否已发送
+func requestLogin(loginChan chan bool, force bool) bool {
+	select {
+	case loginChan <- force:
+		return true
+	default:
+	}
+	if !force {
+		return false
+	}
+	select {
+	case <-loginChan:
+	default:
+	}
+	select {
+	case loginChan <- force:
+		return true
+	default:
+		return false
+	}
+}
+
+// handleSessionLost 保活检测到会话失效时立即强制认证
+// 门户在会话失效后通常仍响应ping，普通登录会误判为已连接并在失效的会话上重新保活
+// 参数:
+//   - ka: 会话保活
+//   - err: 保活错误
+//   - pause: 登录暂停状态
+//   - loginChan: 登录请求通道
+func handleSessionLost(ka *keepaliveLoop, err error, pause *loginPause, loginChan chan bool) {
+	log.Println("Keepalive failed, Err: ", err)
+	ka.sessionLost = true
+	if !pause.paused {
+		requestLogin(loginChan, true)
+	}
+}
+
 // loginResult 登录结果结构体，用于在goroutine之间传递结果
 type loginResult struct {
-	res string // 登录响应消息
-	err error  // 错误信息
+	res *LoginResult // 认证结果
+	err error        // 错误信息
 }
 
 // Execute 将所有子命令添加到根命令并适当设置标志
@@ -242,7 +329,7 @@ type loginResult struct {
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(ExitCode(err))
 	}
 }
 
@@ -261,13 +348,28 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&account, "account", "a", "", "锐捷网络认证账号")
 	rootCmd.PersistentFlags().StringVarP(&password, "password", "p", "", "锐捷网络认证密码")
 	rootCmd.PersistentFlags().StringVarP(&serviceType, "serviceType", "s", "internet", "服务类型，选项: [internet, local]")
-	rootCmd.PersistentFlags().BoolVarP(&encrypt, "encrypt", "e", false, "密码是否加密 (默认 false)")
-	
+	rootCmd.PersistentFlags().BoolVarP(&encrypt, "encrypt", "e", false, "是否使用门户公钥RSA加密密码 (默认 false)")
+	rootCmd.PersistentFlags().StringSliceVar(&encryptScripts, "encryptScript", nil, "加密密码使用的JavaScript脚本路径或URL，可指定多个，相对URL根据登录URL解析")
+	rootCmd.PersistentFlags().StringVar(&encryptFunction, "encryptFunction", "encrypt", "加密脚本中的加密函数名，参数为密码、查询参数和门户公钥")
+	rootCmd.PersistentFlags().BoolVar(&logoutOnStop, "logoutOnStop", false, "服务或守护进程停止时是否下线 (默认 false)")
+	rootCmd.PersistentFlags().StringVar(&authBackend, "backend", defaultAuthBackend, "认证后端，选项: [ruijie, srun, drcom, generic, dot1x, plugin]")
+
+	// CAS配置
+	rootCmd.PersistentFlags().BoolVar(&casEnable, "cas", false, "门户跳转到CAS统一身份认证时启用，通过CAS登录 (登录URL指向CAS时自动启用)")
+
+	// 802.1X认证配置
+	rootCmd.PersistentFlags().StringVar(&dot1xInterface, "dot1xInterface", "", "802.1X认证使用的有线网络接口")
+	rootCmd.PersistentFlags().StringVar(&dot1xMulticast, "dot1xMulticast", "standard", "EAPOL-Start的组播地址，选项: [standard, ruijie]")
+
+	// 验证码配置
+	rootCmd.PersistentFlags().StringVar(&captchaSolver, "captchaSolver", "", "外部验证码识别命令，验证码图片路径作为最后一个参数传入，输出识别结果")
+
 	// User-Agent配置
 	rootCmd.PersistentFlags().StringVar(&userAgent, "userAgent", "", "自定义User-Agent字符串 (默认使用内置值)")
-	
+
 	// Ping配置
-	rootCmd.PersistentFlags().StringVar(&pingIP, "pingIP", "202.114.0.131", "Ping的目标IP地址")
+	rootCmd.PersistentFlags().StringSliceVar(&pingIPs, "pingIP", []string{"202.114.0.131"}, "Ping的目标IP地址，可指定多个，并发Ping")
+	rootCmd.PersistentFlags().IntVar(&pingQuorum, "pingQuorum", 1, "判定已连接需要可达的目标数量，0表示过半")
 	rootCmd.PersistentFlags().IntVar(&pingCount, "pingCount", 3, "Ping次数")
 	rootCmd.PersistentFlags().DurationVar(&pingTimeout, "pingTimeout", 3*time.Second, "Ping超时时间")
 	rootCmd.PersistentFlags().BoolVar(&pingPrivilege, "pingPrivilege", true, `设置ping发送的类型。
@@ -275,23 +377,37 @@ false表示发送"非特权"UDP ping。
 true表示发送"特权"原始ICMP ping。
 注意：设置为true需要超级用户权限。
 `)
-	
+	rootCmd.PersistentFlags().StringVar(&pingFallbackURL, "pingFallbackURL", "http://connect.rom.miui.com/generate_204", "无权发送ICMP时改用HTTP检测的地址，不跟随跳转，状态码与#后的期望值 (默认204) 一致时视为已连接，为空表示不回退")
+
+	// 连接检测配置
+	rootCmd.PersistentFlags().StringSliceVar(&detectSpecs, "detect", []string{"icmp"}, "连接检测器，可指定多个，选项: [icmp[:ip], http:url[#status], tcp:host:port, dns:name[@server]]")
+	rootCmd.PersistentFlags().StringVar(&detectRule, "detectRule", "any", "多个连接检测器的组合规则，选项: [any, all, quorum]")
+	rootCmd.PersistentFlags().IntVar(&detectQuorum, "detectQuorum", 0, "quorum规则下需要判定已连接的检测器数量 (默认过半)")
+	rootCmd.PersistentFlags().DurationVar(&detectTimeout, "detectTimeout", 3*time.Second, "HTTP、TCP和DNS检测的超时时间")
+
 	// 重定向和日志配置
 	rootCmd.PersistentFlags().StringVar(&redirectURL, "redirectURL", "http://123.123.123.123", "重定向URL")
+	rootCmd.PersistentFlags().StringVar(&portalURL, "portalURL", "", "门户登录页面地址，设置后不访问重定向URL，直接构建查询字符串")
+	rootCmd.PersistentFlags().DurationVar(&portalCacheTTL, "portalCacheTTL", 24*time.Hour, "上次认证成功的门户的缓存有效期，断网后优先使用缓存直接认证，0表示不使用缓存")
+	rootCmd.PersistentFlags().StringVar(&portalInterface, "portalInterface", "", "直连认证时获取用户IP和MAC地址的网络接口 (默认根据路由选择)")
 	rootCmd.PersistentFlags().StringVar(&logDir, "logDir", filepath.Join(os.TempDir(), "HustWebAuth"), "日志目录")
 	rootCmd.PersistentFlags().StringVarP(&logFile, "logFile", "l", "", "日志文件名 (默认表示输出到os.stdout)")
 	rootCmd.PersistentFlags().BoolVar(&logRandom, "logRandom", true, "日志文件名是否包含随机字符串。\n注意: 如果logFile包含\"*\"，随机字符串将替换最后一个\"*\"。\n")
 	rootCmd.PersistentFlags().BoolVar(&logAppend, "logAppend", true, "日志文件是否追加模式。\n注意: 如果logRandom为true，此设置将被忽略")
 	rootCmd.PersistentFlags().BoolVar(&logConnected, "logConnected", true, "是否记录\"网络已连接\"的日志")
 	rootCmd.PersistentFlags().BoolVar(&sysLog, "syslog", false, "启用系统日志，不支持Windows")
-	
+
 	// 其他配置
 	rootCmd.PersistentFlags().BoolVarP(&saveCfg, "save", "o", false, "保存配置文件")
-	
+
 	// 守护进程配置
 	rootCmd.Flags().BoolVarP(&daemonEnable, "daemon", "d", false, "启用守护进程模式，不支持Windows")
 	rootCmd.Flags().StringVar(&daemonPidFile, "daemonPidFile", "", "守护进程PID文件")
-	
+
+	// 保活配置
+	rootCmd.Flags().BoolVar(&keepaliveEnable, "keepalive", true, "循环模式下是否向门户发送保活请求")
+	rootCmd.Flags().DurationVar(&keepaliveInterval, "keepaliveInterval", 0, "保活间隔，0表示使用门户下发的间隔")
+
 	// 循环模式配置
 	rootCmd.Flags().BoolVarP(&cycleEnable, "cycle", "c", false, "启用循环模式")
 	rootCmd.Flags().DurationVar(&cycleDuration, "cycleDuration", 5*time.Minute, "循环间隔时间")
@@ -306,12 +422,29 @@ true表示发送"特权"原始ICMP ping。
 	viper.BindPFlag("auth.password", rootCmd.PersistentFlags().Lookup("password"))
 	viper.BindPFlag("auth.serviceType", rootCmd.PersistentFlags().Lookup("serviceType"))
 	viper.BindPFlag("auth.encrypt", rootCmd.PersistentFlags().Lookup("encrypt"))
+	viper.BindPFlag("auth.encryptScript", rootCmd.PersistentFlags().Lookup("encryptScript"))
+	viper.BindPFlag("auth.encryptFunction", rootCmd.PersistentFlags().Lookup("encryptFunction"))
+	viper.BindPFlag("auth.logoutOnStop", rootCmd.PersistentFlags().Lookup("logoutOnStop"))
+	viper.BindPFlag("auth.backend", rootCmd.PersistentFlags().Lookup("backend"))
+	viper.BindPFlag("auth.cas", rootCmd.PersistentFlags().Lookup("cas"))
+	viper.BindPFlag("dot1x.interface", rootCmd.PersistentFlags().Lookup("dot1xInterface"))
+	viper.BindPFlag("dot1x.multicast", rootCmd.PersistentFlags().Lookup("dot1xMulticast"))
 	viper.BindPFlag("auth.userAgent", rootCmd.PersistentFlags().Lookup("userAgent"))
+	viper.BindPFlag("captcha.solver", rootCmd.PersistentFlags().Lookup("captchaSolver"))
 	viper.BindPFlag("ping.ip", rootCmd.PersistentFlags().Lookup("pingIP"))
+	viper.BindPFlag("ping.quorum", rootCmd.PersistentFlags().Lookup("pingQuorum"))
 	viper.BindPFlag("ping.count", rootCmd.PersistentFlags().Lookup("pingCount"))
 	viper.BindPFlag("ping.timeout", rootCmd.PersistentFlags().Lookup("pingTimeout"))
 	viper.BindPFlag("ping.privilege", rootCmd.PersistentFlags().Lookup("pingPrivilege"))
+	viper.BindPFlag("ping.fallbackURL", rootCmd.PersistentFlags().Lookup("pingFallbackURL"))
+	viper.BindPFlag("detect.detectors", rootCmd.PersistentFlags().Lookup("detect"))
+	viper.BindPFlag("detect.rule", rootCmd.PersistentFlags().Lookup("detectRule"))
+	viper.BindPFlag("detect.quorum", rootCmd.PersistentFlags().Lookup("detectQuorum"))
+	viper.BindPFlag("detect.timeout", rootCmd.PersistentFlags().Lookup("detectTimeout"))
 	viper.BindPFlag("redirect.url", rootCmd.PersistentFlags().Lookup("redirectURL"))
+	viper.BindPFlag("portal.url", rootCmd.PersistentFlags().Lookup("portalURL"))
+	viper.BindPFlag("portal.cacheTTL", rootCmd.PersistentFlags().Lookup("portalCacheTTL"))
+	viper.BindPFlag("portal.interface", rootCmd.PersistentFlags().Lookup("portalInterface"))
 	viper.BindPFlag("log.dir", rootCmd.PersistentFlags().Lookup("logDir"))
 	viper.BindPFlag("log.file", rootCmd.PersistentFlags().Lookup("logFile"))
 	viper.BindPFlag("log.random", rootCmd.PersistentFlags().Lookup("logRandom"))
@@ -320,6 +453,8 @@ true表示发送"特权"原始ICMP ping。
 	viper.BindPFlag("log.syslog", rootCmd.PersistentFlags().Lookup("sysLog"))
 	viper.BindPFlag("daemon.enable", rootCmd.Flags().Lookup("daemon"))
 	viper.BindPFlag("daemon.pidFile", rootCmd.Flags().Lookup("daemonPidFile"))
+	viper.BindPFlag("keepalive.enable", rootCmd.Flags().Lookup("keepalive"))
+	viper.BindPFlag("keepalive.interval", rootCmd.Flags().Lookup("keepaliveInterval"))
 	viper.BindPFlag("cycle.enable", rootCmd.Flags().Lookup("cycle"))
 	viper.BindPFlag("cycle.duration", rootCmd.Flags().Lookup("cycleDuration"))
 	viper.BindPFlag("cycle.retry", rootCmd.Flags().Lookup("cycleRetry"))
@@ -361,30 +496,54 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		log.Println("Using config file: " + viper.ConfigFileUsed())
 		// 从配置文件中读取各项配置
-		account = viper.GetString("auth.account")
-		password = viper.GetString("auth.password")
-		serviceType = viper.GetString("auth.serviceType")
-		encrypt = viper.GetBool("auth.encrypt")
-		userAgent = viper.GetString("auth.userAgent")
-		pingIP = viper.GetString("ping.ip")
-		pingCount = viper.GetInt("ping.count")
-		pingTimeout = viper.GetDuration("ping.timeout")
-		pingPrivilege = viper.GetBool("ping.privilege")
-		redirectURL = viper.GetString("redirect.url")
-		logDir = viper.GetString("log.dir")
-		logFile = viper.GetString("log.file")
-		logRandom = viper.GetBool("log.random")
-		logAppend = viper.GetBool("log.append")
-		logConnected = viper.GetBool("log.connected")
-		sysLog = viper.GetBool("log.syslog")
-		daemonEnable = viper.GetBool("daemon.enable")
-		daemonPidFile = viper.GetString("daemon.pidFile")
-		cycleEnable = viper.GetBool("cycle.enable")
-		cycleDuration = viper.GetDuration("cycle.duration")
-		cycleRetry = viper.GetInt("cycle.retry")
+		loadConfig()
 	}
 }
 
+// loadConfig 从Viper读取各项配置到全局变量
+func loadConfig() {
+	account = viper.GetString("auth.account")
+	password = viper.GetString("auth.password")
+	serviceType = viper.GetString("auth.serviceType")
+	encrypt = viper.GetBool("auth.encrypt")
+	encryptScripts = viper.GetStringSlice("auth.encryptScript")
+	encryptFunction = viper.GetString("auth.encryptFunction")
+	logoutOnStop = viper.GetBool("auth.logoutOnStop")
+	authBackend = viper.GetString("auth.backend")
+	casEnable = viper.GetBool("auth.cas")
+	dot1xInterface = viper.GetString("dot1x.interface")
+	dot1xMulticast = viper.GetString("dot1x.multicast")
+	userAgent = viper.GetString("auth.userAgent")
+	captchaSolver = viper.GetString("captcha.solver")
+	pingIPs = viper.GetStringSlice("ping.ip")
+	pingQuorum = viper.GetInt("ping.quorum")
+	pingCount = viper.GetInt("ping.count")
+	pingTimeout = viper.GetDuration("ping.timeout")
+	pingPrivilege = viper.GetBool("ping.privilege")
+	pingFallbackURL = viper.GetString("ping.fallbackURL")
+	detectSpecs = viper.GetStringSlice("detect.detectors")
+	detectRule = viper.GetString("detect.rule")
+	detectQuorum = viper.GetInt("detect.quorum")
+	detectTimeout = viper.GetDuration("detect.timeout")
+	redirectURL = viper.GetString("redirect.url")
+	portalURL = viper.GetString("portal.url")
+	portalInterface = viper.GetString("portal.interface")
+	portalCacheTTL = viper.GetDuration("portal.cacheTTL")
+	logDir = viper.GetString("log.dir")
+	logFile = viper.GetString("log.file")
+	logRandom = viper.GetBool("log.random")
+	logAppend = viper.GetBool("log.append")
+	logConnected = viper.GetBool("log.connected")
+	sysLog = viper.GetBool("log.syslog")
+	daemonEnable = viper.GetBool("daemon.enable")
+	daemonPidFile = viper.GetString("daemon.pidFile")
+	keepaliveEnable = viper.GetBool("keepalive.enable")
+	keepaliveInterval = viper.GetDuration("keepalive.interval")
+	cycleEnable = viper.GetBool("cycle.enable")
+	cycleDuration = viper.GetDuration("cycle.duration")
+	cycleRetry = viper.GetInt("cycle.retry")
+}
+
 // GetUserAgent 获取User-Agent字符串
 // 优先使用配置文件或命令行参数中定义的User-Agent
 // 如果未定义，则使用默认值
@@ -0,0 +1,244 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 锐捷ePortal认证后端
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ruijieAuthenticator 锐捷ePortal认证后端
+type ruijieAuthenticator struct{}
+
+// init 注册锐捷认证后端
+func init() {
+	registerAuthenticator("ruijie", func() Authenticator { return &ruijieAuthenticator{} })
+}
+
+// Name 返回后端名称
+func (a *ruijieAuthenticator) Name() string {
+	return "ruijie"
+}
+
+// Detect 从重定向页面中提取登录URL和查询字符串
+// Dr.COM门户的页面同样包含跳转地址，先按Dr.COM特征识别，交由drcom后端认证
+func (a *ruijieAuthenticator) Detect(page *LandingPage) (*Portal, error) {
+	if drcomMarkerRegexp.MatchString(page.Body) {
+		portal, err := (&drcomAuthenticator{}).Detect(page)
+		if err != nil {
+			return nil, err
+		}
+		portal.Backend = "drcom"
+		return portal, nil
+	}
+	redirect, err := parseRedirect(page)
+	if err != nil {
+		return nil, err
+	}
+	return &Portal{LoginURL: redirect.URL, QueryString: loginQueryString(redirect.URL), Form: redirect.Form}, nil
+}
+
+// Login 在锐捷门户上认证
+// 依次获取Cookie和页面信息，校验服务类型、加密密码后登录，必要时识别验证码和注册MAC地址
+func (a *ruijieAuthenticator) Login(portal *Portal) (*LoginResult, error) {
+	// 获取认证Cookie，使用缓存的门户时复用缓存的Cookie
+	cookie := portal.Cookie
+	var err error
+	if cookie == nil {
+		if cookie, err = GetCookie(portal.LoginURL); err != nil {
+			return nil, err
+		}
+		portal.Cookie = cookie
+	}
+
+	// 获取页面信息，用于校验服务类型和加密密码
+	info, infoErr := getPageInfo(portal.LoginURL, portal.QueryString)
+
+	// 校验服务类型，门户不支持页面信息时跳过
+	loginService := serviceType
+	if infoErr == nil {
+		loginService, err = matchService(serviceType, info.Service.List)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 配置了CAS或登录URL指向CAS时，先尝试通过CAS登录，门户未跳转到CAS时使用账号密码登录
+	var loginRes *PortalResponse
+	if casEnable || casURLRegexp.MatchString(portal.LoginURL) {
+		loginRes, err = casPortalLogin(portal.LoginURL, cookie)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if loginRes == nil {
+		loginRes, err = passwordLogin(portal, info, infoErr, loginService, cookie)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := &LoginResult{
+		KeepaliveInterval: time.Duration(loginRes.KeepaliveInterval) * time.Second,
+		Response:          loginRes,
+	}
+
+	// 记录本次会话，供下线时使用
+	setSession(&portalSession{
+		Backend:     a.Name(),
+		LoginURL:    portal.LoginURL,
+		QueryString: portal.QueryString,
+		UserIndex:   loginRes.UserIndex,
+		Account:     account,
+		Time:        time.Now(),
+	})
+
+	// 如果需要注册MAC地址
+	if register {
+		// 如果不支持注册服务，重置注册标志
+		if loginRes.UserIndex == "" {
+			register = false
+			log.Println("Unsupport register service.")
+			return res, nil
+		}
+		// 注册MAC地址
+		res.Register, err = RegisterMAC(portal.LoginURL, loginRes.UserIndex, "", cookie)
+		if err != nil {
+			register = false
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// passwordLogin 使用账号密码在锐捷门户上登录
+// 按配置加密密码，门户要求验证码时识别后重新登录
+// 参数:
+//   - portal: 门户
+//   - info: 页面信息
+//   - infoErr: 获取页面信息时的错误，门户不支持页面信息时不为nil
+//   - loginService: 校验后的服务类型
+//   - cookie: HTTP Cookie
+// 返回值: 登录成功的门户响应和可能的错误
+func passwordLogin(portal *Portal, info *PortalResponse, infoErr error, loginService string, cookie *http.Cookie) (*PortalResponse, error) {
+	// 如果需要加密，优先使用配置的加密脚本，否则使用门户公钥加密密码
+	var err error
+	loginPassword := password
+	passwordEncrypted := encrypt
+	if len(encryptScripts) > 0 {
+		if infoErr != nil {
+			info = nil
+		}
+		loginPassword, err = scriptEncryptPassword(portal.LoginURL, portal.QueryString, password, info)
+		if err != nil {
+			return nil, err
+		}
+		passwordEncrypted = true
+	} else if encrypt {
+		if infoErr != nil {
+			return nil, infoErr
+		}
+		loginPassword, err = getEncryptedPassword(info, portal.QueryString, password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 执行登录认证
+	loginRes, err := login(portal.LoginURL, portal.QueryString, account, loginPassword, loginService, passwordEncrypted, "", cookie)
+	if err != nil {
+		return nil, err
+	}
+
+	// 如果门户要求验证码，识别后重新登录
+	if !loginRes.Success() && loginRes.ValidCodeURL != "" {
+		validcode, err := solveCaptcha(portal.LoginURL, loginRes.ValidCodeURL, cookie)
+		if err != nil {
+			return nil, err
+		}
+		loginRes, err = login(portal.LoginURL, portal.QueryString, account, loginPassword, loginService, passwordEncrypted, validcode, cookie)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 检查登录结果
+	if !loginRes.Success() {
+		return nil, classifyPortalResponse("Login fail", loginRes)
+	}
+	return loginRes, nil
+}
+
+// Logout 下线锐捷门户会话
+// 会话未记录用户索引时，从门户查询
+func (a *ruijieAuthenticator) Logout(session *portalSession) (string, error) {
+	userIndex := session.UserIndex
+	if userIndex == "" {
+		var err error
+		userIndex, err = getOnlineUserIndex(session.LoginURL)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// 执行下线
+	logoutRes, err := logout(session.LoginURL, userIndex)
+	if err != nil {
+		return "", err
+	}
+
+	// 检查下线结果
+	if !logoutRes.Success() {
+		return "", errors.New("Logout fail: " + logoutRes.String())
+	}
+	return logoutRes.Message, nil
+}
+
+// Status 查询锐捷门户的在线会话
+func (a *ruijieAuthenticator) Status(session *portalSession) (*OnlineStatus, error) {
+	info, err := lookupOnlineUserInfo(session.LoginURL, session.UserIndex)
+	if err != nil {
+		return nil, err
+	}
+	status := &OnlineStatus{
+		Account: info.UserID,
+		Name:    info.UserName,
+		IP:      info.UserIP,
+		MAC:     info.UserMac,
+		Service: info.RealServiceName,
+	}
+	if status.Service == "" {
+		status.Service = info.Service.Name
+	}
+	// 转换在线时长、已用流量等统计信息
+	for _, item := range info.balls() {
+		value := item.Value
+		if item.Type == "time" {
+			// 在线时长以秒为单位
+			if seconds, err := strconv.ParseInt(item.Value, 10, 64); err == nil {
+				value = formatSeconds(seconds)
+			}
+		}
+		status.Items = append(status.Items, statusItem{Name: item.DisplayName, Value: value})
+	}
+	return status, nil
+}
+
+// Keepalive 向锐捷门户发送保活请求
+func (a *ruijieAuthenticator) Keepalive(session *portalSession) error {
+	res, err := keepalive(session.LoginURL, session.UserIndex)
+	if err != nil {
+		return err
+	}
+	if !res.Success() {
+		return fmt.Errorf("%w: %s", errSessionLost, res.String())
+	}
+	return nil
+}
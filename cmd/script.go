@@ -0,0 +1,195 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 门户JavaScript加密脚本相关功能
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	urlutil "net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// 加密脚本相关变量
+var (
+	encryptScripts  []string // 加密脚本的本地路径或URL，按顺序加载
+	encryptFunction string   // 加密脚本中的加密函数名
+)
+
+// 加密脚本的执行限制
+const (
+	scriptTimeout = 5 * time.Second // 加载脚本或调用函数的最长时间
+	maxScriptSize = 4 << 20         // 脚本的最大长度（字节）
+)
+
+// scriptEngine 嵌入的JavaScript解释器，用于执行门户页面中的加密脚本
+type scriptEngine struct {
+	vm *goja.Runtime
+}
+
+// newScriptEngine 创建JavaScript解释器并依次加载脚本
+// 提供window、navigator、document和console的最小实现，以便运行为浏览器编写的脚本
+// 参数:
+//   - baseUrl: 解析相对脚本地址使用的URL，通常为登录URL
+//   - sources: 脚本的本地路径或URL
+// 返回值: JavaScript解释器和可能的错误
+func newScriptEngine(baseUrl string, sources []string) (*scriptEngine, error) {
+	vm := goja.New()
+	global := vm.GlobalObject()
+	global.Set("window", global)
+	global.Set("self", global)
+	global.Set("navigator", map[string]interface{}{"appName": "Netscape", "userAgent": GetUserAgent()})
+	global.Set("document", map[string]interface{}{})
+	global.Set("console", map[string]interface{}{
+		"log": func(args ...interface{}) {
+			log.Println(append([]interface{}{"Script:"}, args...)...)
+		},
+	})
+
+	e := &scriptEngine{vm: vm}
+	for _, source := range sources {
+		code, err := readScript(baseUrl, source)
+		if err != nil {
+			return nil, err
+		}
+		if err = e.run(source, code); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// run 执行脚本，超过scriptTimeout时中断
+// 参数:
+//   - name: 脚本名称，用于错误信息
+//   - code: 脚本内容
+// 返回值: 可能的错误
+func (e *scriptEngine) run(name string, code string) error {
+	timer := time.AfterFunc(scriptTimeout, func() { e.vm.Interrupt("script timed out") })
+	defer timer.Stop()
+	defer e.vm.ClearInterrupt()
+	if _, err := e.vm.RunScript(name, code); err != nil {
+		return fmt.Errorf("run script %s: %w", name, err)
+	}
+	return nil
+}
+
+// call 调用脚本中的全局函数，超过scriptTimeout时中断
+// 参数:
+//   - name: 函数名，支持对象方法如RSAUtils.encryptedString
+//   - args: 函数参数，map和slice转换为JavaScript对象和数组
+// 返回值: 转换为字符串的返回值和可能的错误
+func (e *scriptEngine) call(name string, args ...interface{}) (string, error) {
+	var this, value goja.Value = goja.Undefined(), e.vm.GlobalObject()
+	for _, part := range strings.Split(name, ".") {
+		obj := value.ToObject(e.vm)
+		this, value = obj, obj.Get(part)
+		if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+			return "", fmt.Errorf("script function %s is not defined", name)
+		}
+	}
+	fn, ok := goja.AssertFunction(value)
+	if !ok {
+		return "", fmt.Errorf("script %s is not a function", name)
+	}
+
+	values := make([]goja.Value, len(args))
+	for i, arg := range args {
+		values[i] = e.vm.ToValue(arg)
+	}
+	timer := time.AfterFunc(scriptTimeout, func() { e.vm.Interrupt("script timed out") })
+	defer timer.Stop()
+	defer e.vm.ClearInterrupt()
+	res, err := fn(this, values...)
+	if err != nil {
+		return "", fmt.Errorf("call script function %s: %w", name, err)
+	}
+	return res.String(), nil
+}
+
+// readScript 读取脚本内容
+// 包含协议的地址和本地不存在的路径作为URL，相对地址根据baseUrl解析后下载
+// 参数:
+//   - baseUrl: 解析相对地址使用的URL
+//   - source: 脚本的本地路径或URL
+// 返回值: 脚本内容和可能的错误
+func readScript(baseUrl string, source string) (string, error) {
+	if !strings.Contains(source, "://") {
+		if data, err := os.ReadFile(source); err == nil {
+			return string(data), nil
+		} else if !errors.Is(err, os.ErrNotExist) || baseUrl == "" {
+			return "", err
+		}
+	}
+
+	base, err := urlutil.Parse(baseUrl)
+	if err != nil {
+		return "", err
+	}
+	ref, err := base.Parse(source)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodGet, ref.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", GetUserAgent())
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: download script %s: %s", ErrPortalServer, ref, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxScriptSize))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	return string(data), nil
+}
+
+// scriptEncryptPassword 使用加密脚本加密密码
+// 加密函数的参数依次为明文密码、查询参数对象和门户公钥对象（含exponent和modulus，门户未提供时为空对象）
+// 参数:
+//   - loginUrl: 登录URL，用于解析相对脚本地址
+//   - queryString: 查询字符串
+//   - password: 明文密码
+//   - info: 页面信息，可为nil
+// 返回值: 加密后的密码和可能的错误
+func scriptEncryptPassword(loginUrl string, queryString string, password string, info *PortalResponse) (string, error) {
+	e, err := newScriptEngine(loginUrl, encryptScripts)
+	if err != nil {
+		return "", err
+	}
+
+	query := map[string]interface{}{}
+	values := parsePortalQuery(queryString).Values
+	for k := range values {
+		if v := values.Get(k); v != "" {
+			query[k] = v
+		}
+	}
+	query["mac"] = getQueryMac(queryString)
+	keys := map[string]interface{}{}
+	if info != nil && info.PublicKeyExponent != "" {
+		keys["exponent"] = info.PublicKeyExponent
+		keys["modulus"] = info.PublicKeyModulus
+	}
+
+	name := encryptFunction
+	if name == "" {
+		name = "encrypt"
+	}
+	return e.call(name, password, query, keys)
+}
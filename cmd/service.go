@@ -97,7 +97,7 @@ var (
 				log.Fatal(err)
 				return
 			}
-			
+
 			// 在OpenWrt上，安装后必须运行enable命令，否则服务不会在系统启动时自动启动
 			if IsOpenWrt() {
 				_, err = runInitdCommand(s.String(), "enable")
@@ -155,7 +155,7 @@ var (
 				log.Fatal(err)
 				return
 			}
-			
+
 			// 根据状态输出相应信息
 			switch status {
 			case service.StatusUnknown:
@@ -231,7 +231,7 @@ var (
 				log.Fatal(err)
 				return
 			}
-			
+
 			// 如果服务正在运行，先停止它
 			if status == service.StatusRunning {
 				err = svcAction(s, "stop")
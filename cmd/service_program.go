@@ -23,8 +23,9 @@ func (p *program) run() {
 }
 
 // Stop 实现服务停止接口
-// 当服务停止时调用此方法
+// 当服务停止时调用此方法，如果启用logoutOnStop则先下线
 func (p *program) Stop(service.Service) error {
 	log.Println("Stoping HustWebAuth service...")
+	logoutOnShutdown()
 	return nil
 }
@@ -0,0 +1,203 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 门户服务查询和校验相关功能
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// servicesCmd 表示服务查询命令
+var servicesCmd = &cobra.Command{
+	Use:   "services",
+	Short: "List the services provided by the portal",
+	Long: `Fetch the page info of the portal for the current redirect query string
+and list the selectable services with their display names.
+The service name is the value of the serviceType option.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// 获取登录URL和查询字符串
+		url, queryString, err := getPortalQuery()
+		if err != nil {
+			fatal(err)
+		}
+		// 获取页面信息
+		info, err := getPageInfo(url, queryString)
+		if err != nil {
+			fatal(err)
+		}
+		if len(info.Service.List) == 0 {
+			log.Println("The portal does not provide a service list")
+			return
+		}
+		// 输出服务列表，标记当前配置的服务
+		for _, s := range info.Service.List {
+			mark := " "
+			if s.Name == serviceType {
+				mark = "*"
+			}
+			log.Println(mark, s.Name, "-", s.DisplayName)
+		}
+	},
+}
+
+// init 初始化services命令
+func init() {
+	// 将services命令添加到root命令下
+	rootCmd.AddCommand(servicesCmd)
+}
+
+// portalService 门户提供的可选服务
+type portalService struct {
+	Name        string // 服务名称，登录时作为service参数提交
+	DisplayName string // 服务显示名称
+}
+
+// serviceField 兼容门户以字符串、对象或数组返回的service字段
+// getOnlineUserInfo返回当前服务名称，pageInfo返回可选服务列表
+type serviceField struct {
+	Name string          // 字符串形式时的服务名称
+	List []portalService // 对象或数组形式时的服务列表
+}
+
+// UnmarshalJSON 实现json.Unmarshaler接口
+func (f *serviceField) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		// 部分门户将服务列表以JSON字符串的形式返回
+		trimmed := strings.TrimSpace(s)
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			return f.UnmarshalJSON([]byte(trimmed))
+		}
+		f.Name = s
+		return nil
+	}
+
+	// 数组形式: ["internet", ...] 或 [{"serviceName": "internet", "serviceShowName": "互联网"}, ...]
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err == nil {
+		for _, item := range items {
+			if s, ok := parseServiceItem(item); ok {
+				f.List = append(f.List, s)
+			}
+		}
+		return nil
+	}
+
+	// 对象形式: {"internet": "互联网", ...}，按原顺序解析
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var value interface{}
+		if err = dec.Decode(&value); err != nil {
+			return err
+		}
+		name, _ := key.(string)
+		display, _ := value.(string)
+		if display == "" {
+			display = name
+		}
+		f.List = append(f.List, portalService{Name: name, DisplayName: display})
+	}
+	return nil
+}
+
+// parseServiceItem 解析服务列表中的单个服务
+// 返回值: 服务和是否解析成功
+func parseServiceItem(item json.RawMessage) (portalService, bool) {
+	var name string
+	if json.Unmarshal(item, &name) == nil {
+		return portalService{Name: name, DisplayName: name}, name != ""
+	}
+	var obj map[string]interface{}
+	if json.Unmarshal(item, &obj) != nil {
+		return portalService{}, false
+	}
+	// 取第一个存在的字段
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v, ok := obj[k].(string); ok && v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	s := portalService{
+		Name:        first("serviceName", "name", "id", "value"),
+		DisplayName: first("serviceShowName", "displayName", "showName", "label"),
+	}
+	if s.DisplayName == "" {
+		s.DisplayName = s.Name
+	}
+	return s, s.Name != ""
+}
+
+// matchService 校验配置的服务类型是否为门户提供的服务
+// 依次尝试精确匹配、忽略大小写匹配名称或显示名称、唯一的部分匹配
+// 参数:
+//   - name: 配置的服务类型
+//   - services: 门户提供的服务列表，为空时不校验
+// 返回值: 提交给门户的服务名称和可能的错误
+func matchService(name string, services []portalService) (string, error) {
+	if name == "none" || len(services) == 0 {
+		return name, nil
+	}
+	for _, s := range services {
+		if s.Name == name {
+			return s.Name, nil
+		}
+	}
+	for _, s := range services {
+		if strings.EqualFold(s.Name, name) || strings.EqualFold(s.DisplayName, name) {
+			return s.Name, nil
+		}
+	}
+	var matched []portalService
+	lower := strings.ToLower(name)
+	for _, s := range services {
+		if strings.Contains(strings.ToLower(s.Name), lower) || strings.Contains(strings.ToLower(s.DisplayName), lower) {
+			matched = append(matched, s)
+		}
+	}
+	if len(matched) == 1 {
+		log.Println("Service", name, "matched to", matched[0].Name, "-", matched[0].DisplayName)
+		return matched[0].Name, nil
+	}
+
+	// 无法匹配时列出可选服务
+	available := make([]string, 0, len(services))
+	for _, s := range services {
+		available = append(available, s.Name+"("+s.DisplayName+")")
+	}
+	return "", fmt.Errorf("%w: unknown service %q, available: %s", ErrInvalidService, name, strings.Join(available, ", "))
+}
+
+// getPortalQuery 获取登录URL和查询字符串
+// 网络已连接时无法获取重定向，使用最近一次登录记录
+// 返回值: 登录URL、查询字符串和可能的错误
+func getPortalQuery() (string, string, error) {
+	url, queryString, connected, err := GetLoginUrl()
+	if err != nil {
+		return "", "", err
+	}
+	if !connected {
+		return url, queryString, nil
+	}
+	s, err := getSession()
+	if err != nil {
+		return "", "", err
+	}
+	return s.LoginURL, s.QueryString, nil
+}
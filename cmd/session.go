@@ -0,0 +1,92 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 认证会话记录相关功能
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// portalSession 最近一次成功认证的会话信息
+type portalSession struct {
+	Backend     string    `json:"backend"`     // 认证后端
+	LoginURL    string    `json:"loginUrl"`    // 登录URL
+	QueryString string    `json:"queryString"` // 查询字符串
+	UserIndex   string    `json:"userIndex"`   // 用户索引
+	Account     string    `json:"account"`     // 认证账号
+	Time        time.Time `json:"time"`        // 认证时间
+
+	Extra map[string]string `json:"extra,omitempty"` // 认证后端需要的其他信息
+}
+
+// 当前会话，登录成功后更新，下线后清空
+var (
+	currentSession *portalSession
+	sessionMutex   sync.Mutex
+)
+
+// getSessionFile 获取会话记录文件路径
+func getSessionFile() string {
+	return filepath.Join(homeDir, "HustWebAuth.session")
+}
+
+// setSession 更新当前会话并写入会话记录文件
+// 写入失败时仅记录日志，不影响认证流程
+func setSession(s *portalSession) {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	currentSession = s
+	data, err := json.Marshal(s)
+	if err != nil {
+		log.Println("Save session failed, Err: ", err)
+		return
+	}
+	if err = os.WriteFile(getSessionFile(), data, 0600); err != nil {
+		log.Println("Save session failed, Err: ", err)
+	}
+}
+
+// getSession 获取当前会话
+// 当前进程中没有会话时，从会话记录文件中读取
+// 返回值: 会话信息和可能的错误
+func getSession() (*portalSession, error) {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	if currentSession != nil {
+		return currentSession, nil
+	}
+	data, err := os.ReadFile(getSessionFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("no session found, please login first")
+		}
+		return nil, err
+	}
+	s := &portalSession{}
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	currentSession = s
+	return s, nil
+}
+
+// clearSession 清空当前会话并删除会话记录文件
+func clearSession() {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	currentSession = nil
+	if err := os.Remove(getSessionFile()); err != nil && !os.IsNotExist(err) {
+		log.Println("Remove session failed, Err: ", err)
+	}
+}
@@ -0,0 +1,459 @@
+/*
+Copyright © 2022 a76yyyy q981331502@163.com
+*/
+
+// 深澜Srun认证后端
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	urlutil "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// 深澜门户固定参数
+const (
+	srunEncVer      = "srun_bx1" // info字段的加密版本
+	srunN           = "200"      // 校验和参数n
+	srunType        = "1"        // 校验和参数type
+	srunDefaultAcID = "1"        // 默认的ac_id
+)
+
+// srunBase64 深澜门户使用的自定义base64编码
+var srunBase64 = base64.NewEncoding("LVoJPiCN2R8G90yg+hmFHuacZ1OWMnrsSTXkYpUq/3dlbfKwv6xztjI7DeBE45QA")
+
+// srunPortalRegexp 匹配重定向页面中的深澜门户地址
+var srunPortalRegexp = regexp.MustCompile(`['"]([^'"]*srun_portal[^'"]*)['"]`)
+
+// srunResponse 深澜门户接口的响应
+// 所有接口共用同一模型，未返回的字段保持零值
+type srunResponse struct {
+	// 通用字段
+	Res      string `json:"res"`       // 请求结果，成功时为ok
+	Error    string `json:"error"`     // 错误标识，成功时为ok
+	ErrorMsg string `json:"error_msg"` // 错误信息
+	SucMsg   string `json:"suc_msg"`   // 成功信息
+
+	// 挑战码，由get_challenge返回
+	Challenge string `json:"challenge"` // 挑战码
+	ClientIP  string `json:"client_ip"` // 客户端IP地址
+
+	// 在线用户信息，由rad_user_info返回
+	UserName      string      `json:"user_name"`      // 认证账号
+	RealName      string      `json:"real_name"`      // 用户姓名
+	OnlineIP      string      `json:"online_ip"`      // 在线IP地址
+	UserMac       string      `json:"user_mac"`       // MAC地址
+	ProductsName  string      `json:"products_name"`  // 产品名称
+	AddTime       json.Number `json:"add_time"`       // 上线时间戳
+	KeepaliveTime json.Number `json:"keepalive_time"` // 最近保活时间戳
+	SumBytes      json.Number `json:"sum_bytes"`      // 已用流量，单位字节
+	UserBalance   json.Number `json:"user_balance"`   // 账户余额
+
+	// 原始响应内容
+	Raw string `json:"-"`
+}
+
+// Success 判断请求是否成功
+func (r *srunResponse) Success() bool {
+	return r.Res == "ok" || r.Error == "ok"
+}
+
+// String 返回用于输出的错误信息
+func (r *srunResponse) String() string {
+	for _, s := range []string{r.ErrorMsg, r.Error, r.Res} {
+		if s != "" && s != "ok" {
+			return s
+		}
+	}
+	return r.Raw
+}
+
+// srunAuthenticator 深澜Srun认证后端
+type srunAuthenticator struct{}
+
+// init 注册深澜认证后端
+func init() {
+	registerAuthenticator("srun", func() Authenticator { return &srunAuthenticator{} })
+}
+
+// Name 返回后端名称
+func (a *srunAuthenticator) Name() string {
+	return "srun"
+}
+
+// Detect 从重定向页面中识别深澜门户
+// 登录URL为门户根地址，查询字符串保留重定向地址中的ac_id等参数
+func (a *srunAuthenticator) Detect(page *LandingPage) (*Portal, error) {
+	base, err := urlutil.Parse(page.URL)
+	if err != nil {
+		return nil, err
+	}
+	// 重定向后的地址即为门户页面，或页面脚本中跳转到门户页面
+	portalUrl := base
+	if !strings.Contains(base.Path, "srun_portal") {
+		match := srunPortalRegexp.FindStringSubmatch(page.Body)
+		if match == nil {
+			return nil, fmt.Errorf("%w: srun portal not found", ErrRedirectNotFound)
+		}
+		if portalUrl, err = base.Parse(match[1]); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRedirectNotFound, err)
+		}
+	}
+	return &Portal{
+		LoginURL:    portalUrl.Scheme + "://" + portalUrl.Host,
+		QueryString: portalUrl.RawQuery,
+	}, nil
+}
+
+// Login 在深澜门户上认证
+// 获取挑战码后计算加密的info字段、密码和校验和，再调用srun_portal登录
+func (a *srunAuthenticator) Login(portal *Portal) (*LoginResult, error) {
+	acID := srunAcID(portal.QueryString)
+
+	// 获取挑战码，门户会返回客户端IP
+	challenge, err := srunRequest(portal.LoginURL, "/cgi-bin/get_challenge", urlutil.Values{
+		"username": {account},
+		"ip":       {srunQueryIP(portal.QueryString)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Challenge == "" {
+		return nil, fmt.Errorf("%w: get challenge: %s", ErrPortalServer, challenge.String())
+	}
+	token := challenge.Challenge
+	ip := challenge.ClientIP
+	if ip == "" {
+		ip = challenge.OnlineIP
+	}
+
+	// 计算加密的info字段、密码和校验和
+	info, err := srunInfo(account, password, ip, acID, token)
+	if err != nil {
+		return nil, err
+	}
+	hmd5 := srunHmacMD5(password, token)
+	chksum := srunChecksum(token, account, hmd5, acID, ip, info)
+
+	// 执行登录认证
+	loginRes, err := srunRequest(portal.LoginURL, "/cgi-bin/srun_portal", urlutil.Values{
+		"action":       {"login"},
+		"username":     {account},
+		"password":     {"{MD5}" + hmd5},
+		"ac_id":        {acID},
+		"ip":           {ip},
+		"chksum":       {chksum},
+		"info":         {info},
+		"n":            {srunN},
+		"type":         {srunType},
+		"os":           {"Windows 10"},
+		"name":         {"Windows"},
+		"double_stack": {"0"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查登录结果，IP已在线时视为成功
+	if !loginRes.Success() && loginRes.Error != "ip_already_online_error" {
+		return nil, classifySrunResponse("Login fail", loginRes)
+	}
+
+	// 记录本次会话，供下线时使用
+	setSession(&portalSession{
+		Backend:     a.Name(),
+		LoginURL:    portal.LoginURL,
+		QueryString: portal.QueryString,
+		Account:     account,
+		Time:        time.Now(),
+		Extra:       map[string]string{"ip": ip, "acId": acID},
+	})
+	return &LoginResult{}, nil
+}
+
+// Logout 下线深澜门户会话
+func (a *srunAuthenticator) Logout(session *portalSession) (string, error) {
+	acID := session.Extra["acId"]
+	if acID == "" {
+		acID = srunAcID(session.QueryString)
+	}
+	// 会话未记录账号或IP时，从门户查询
+	username, ip := session.Account, session.Extra["ip"]
+	if username == "" || ip == "" {
+		info, err := srunUserInfo(session.LoginURL)
+		if err != nil {
+			return "", err
+		}
+		username, ip = info.UserName, info.OnlineIP
+	}
+
+	// 执行下线
+	logoutRes, err := srunRequest(session.LoginURL, "/cgi-bin/srun_portal", urlutil.Values{
+		"action":   {"logout"},
+		"username": {username},
+		"ip":       {ip},
+		"ac_id":    {acID},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 检查下线结果
+	if !logoutRes.Success() {
+		return "", errors.New("Logout fail: " + logoutRes.String())
+	}
+	return logoutRes.SucMsg, nil
+}
+
+// Status 查询深澜门户的在线会话
+func (a *srunAuthenticator) Status(session *portalSession) (*OnlineStatus, error) {
+	info, err := srunUserInfo(session.LoginURL)
+	if err != nil {
+		return nil, err
+	}
+	status := &OnlineStatus{
+		Account: info.UserName,
+		Name:    info.RealName,
+		IP:      info.OnlineIP,
+		MAC:     info.UserMac,
+		Service: info.ProductsName,
+	}
+	// 在线时长为最近保活时间与上线时间之差
+	if add, err := info.AddTime.Int64(); err == nil && add > 0 {
+		if alive, err := info.KeepaliveTime.Int64(); err == nil && alive >= add {
+			status.Items = append(status.Items, statusItem{Name: "在线时长", Value: formatSeconds(alive - add)})
+		}
+	}
+	if info.SumBytes != "" {
+		status.Items = append(status.Items, statusItem{Name: "已用流量", Value: info.SumBytes.String()})
+	}
+	if info.UserBalance != "" {
+		status.Items = append(status.Items, statusItem{Name: "账户余额", Value: info.UserBalance.String()})
+	}
+	return status, nil
+}
+
+// srunUserInfo 查询在线用户信息
+// 参数: loginUrl - 门户根地址
+// 返回值: 在线用户信息和可能的错误，未在线时返回错误
+func srunUserInfo(loginUrl string) (*srunResponse, error) {
+	info, err := srunRequest(loginUrl, "/cgi-bin/rad_user_info", urlutil.Values{})
+	if err != nil {
+		return nil, err
+	}
+	if info.UserName == "" {
+		return nil, errors.New("Get online user info fail: " + info.String())
+	}
+	return info, nil
+}
+
+// srunRequest 调用深澜门户的JSONP接口
+// 参数:
+//   - loginUrl: 门户根地址
+//   - path: 接口路径
+//   - params: 请求参数
+// 返回值: 解析后的响应和可能的错误
+func srunRequest(loginUrl string, path string, params urlutil.Values) (*srunResponse, error) {
+	// 添加JSONP回调和时间戳参数
+	callback := "jQuery" + strconv.FormatInt(time.Now().UnixMilli(), 10)
+	params.Set("callback", callback)
+	params.Set("_", strconv.FormatInt(time.Now().UnixMilli(), 10))
+
+	// 创建GET请求
+	req, err := http.NewRequest("GET", strings.TrimRight(loginUrl, "/")+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", GetUserAgent())
+
+	// 发送请求
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	defer resp.Body.Close()
+	// 读取响应内容
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNetworkUnreachable, err)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%w: %s", ErrPortalServer, resp.Status)
+	}
+
+	// 去除JSONP回调包装
+	bodyStr := trimJSONP(string(body))
+	res := &srunResponse{Raw: bodyStr}
+	if err = json.Unmarshal([]byte(bodyStr), res); err != nil {
+		return nil, fmt.Errorf("%w: invalid response: %s", ErrPortalServer, bodyStr)
+	}
+	return res, nil
+}
+
+// classifySrunResponse 根据深澜门户响应判断失败原因
+// 参数:
+//   - prefix: 错误信息前缀，如"Login fail"
+//   - res: 门户响应
+// 返回值: 带有错误类型的错误
+func classifySrunResponse(prefix string, res *srunResponse) error {
+	msg := res.String()
+	switch {
+	// E2531: 用户不存在，E2553: 密码错误
+	case containsAny(msg, []string{"e2531", "e2553", "user not found", "password is error"}):
+		return fmt.Errorf("%s: %w: %s", prefix, ErrWrongCredentials, msg)
+	// E2606: 用户被禁用，E2616: 欠费
+	case containsAny(msg, []string{"e2606", "e2616", "arrearage", "disabled"}):
+		return fmt.Errorf("%s: %w: %s", prefix, ErrAccountDisabled, msg)
+	}
+	return classifyMessage(prefix, msg)
+}
+
+// srunAcID 从查询字符串中获取ac_id
+// 参数: queryString - 查询字符串
+// 返回值: ac_id，未找到时返回默认值
+func srunAcID(queryString string) string {
+	values, _ := urlutil.ParseQuery(queryString)
+	if acID := values.Get("ac_id"); acID != "" {
+		return acID
+	}
+	return srunDefaultAcID
+}
+
+// srunQueryIP 从查询字符串中获取用户IP
+// 参数: queryString - 查询字符串
+// 返回值: 用户IP，未找到时返回空字符串，由门户根据请求来源确定
+func srunQueryIP(queryString string) string {
+	return parsePortalQuery(queryString).UserIP
+}
+
+// srunHmacMD5 使用挑战码计算密码的HMAC-MD5
+// 参数:
+//   - password: 明文密码
+//   - token: 挑战码
+// 返回值: 十六进制HMAC-MD5
+func srunHmacMD5(password string, token string) string {
+	mac := hmac.New(md5.New, []byte(token))
+	mac.Write([]byte(password))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// srunChecksum 计算登录请求的SHA1校验和
+// 参数:
+//   - token: 挑战码
+//   - username: 认证账号
+//   - hmd5: 密码的HMAC-MD5
+//   - acID: ac_id
+//   - ip: 用户IP
+//   - info: 加密的info字段
+// 返回值: 十六进制SHA1校验和
+func srunChecksum(token string, username string, hmd5 string, acID string, ip string, info string) string {
+	fields := []string{username, hmd5, acID, ip, srunN, srunType, info}
+	str := token + strings.Join(fields, token)
+	sum := sha1.Sum([]byte(str))
+	return hex.EncodeToString(sum[:])
+}
+
+// srunInfo 计算登录请求的info字段
+// 参数:
+//   - username: 认证账号
+//   - password: 明文密码
+//   - ip: 用户IP
+//   - acID: ac_id
+//   - token: 挑战码
+// 返回值: info字段和可能的错误
+func srunInfo(username string, password string, ip string, acID string, token string) (string, error) {
+	// 字段顺序与门户脚本一致，与JSON.stringify相同，不转义<、>和&
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		IP       string `json:"ip"`
+		AcID     string `json:"acid"`
+		EncVer   string `json:"enc_ver"`
+	}{username, password, ip, acID, srunEncVer})
+	if err != nil {
+		return "", err
+	}
+	data := strings.TrimSuffix(buf.String(), "\n")
+	return "{SRBX1}" + srunBase64.EncodeToString(xEncode(data, token)), nil
+}
+
+// srunPack 将字符串按小端序打包为32位整数数组
+// 与门户脚本一致，按UTF-16编码单元（charCodeAt）而非UTF-8字节打包，
+// 超过0xFF的编码单元与相邻单元按位或，长度也按编码单元计算
+// 参数:
+//   - s: 字符串
+//   - withLength: 是否在末尾追加字符串长度
+// 返回值: 32位整数数组
+func srunPack(s string, withLength bool) []uint32 {
+	units := utf16.Encode([]rune(s))
+	v := make([]uint32, (len(units)+3)/4, (len(units)+3)/4+1)
+	for i, u := range units {
+		v[i>>2] |= uint32(u) << (8 * (i & 3))
+	}
+	if withLength {
+		v = append(v, uint32(len(units)))
+	}
+	return v
+}
+
+// srunUnpack 将32位整数数组按小端序展开为字节
+func srunUnpack(v []uint32) []byte {
+	res := make([]byte, 0, len(v)*4)
+	for _, x := range v {
+		res = append(res, byte(x), byte(x>>8), byte(x>>16), byte(x>>24))
+	}
+	return res
+}
+
+// xEncode 深澜门户使用的XXTEA变种加密
+// 参数:
+//   - str: 明文
+//   - key: 密钥，即挑战码
+// 返回值: 密文字节
+func xEncode(str string, key string) []byte {
+	if str == "" {
+		return nil
+	}
+	v := srunPack(str, true)
+	k := srunPack(key, false)
+	for len(k) < 4 {
+		k = append(k, 0)
+	}
+
+	n := uint32(len(v) - 1)
+	z := v[n]
+	var y, m, e, p, d uint32
+	const c = 0x9E3779B9
+	for q := 6 + 52/(n+1); q > 0; q-- {
+		d += c
+		e = d >> 2 & 3
+		for p = 0; p < n; p++ {
+			y = v[p+1]
+			m = (z>>5 ^ y<<2) + ((y>>3 ^ z<<4) ^ (d ^ y)) + (k[(p&3)^e] ^ z)
+			v[p] += m
+			z = v[p]
+		}
+		y = v[0]
+		m = (z>>5 ^ y<<2) + ((y>>3 ^ z<<4) ^ (d ^ y)) + (k[(p&3)^e] ^ z)
+		v[n] += m
+		z = v[n]
+	}
+	return srunUnpack(v)
+}
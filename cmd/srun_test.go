@@ -0,0 +1,80 @@
+package cmd
+
+import "testing"
+
+// testSrunToken 深澜门户get_challenge下发的挑战码
+const testSrunToken = "9cd4e9b0a3c2fd4c8b5a6e1f7d2c3b4a5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b"
+
+// 预期结果由门户脚本的xEncode和jquery.base64.js计算，HMAC-MD5和SHA1使用标准实现计算
+func TestSrunEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		str  string
+		key  string
+		want string
+	}{
+		{name: "single byte", str: "a", key: "k", want: "P0C27CimGZH="},
+		{name: "short key", str: "hello world", key: "token", want: "d1SFvIAUBarALTnD6LhP0L=="},
+		{name: "empty", str: "", key: "token", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := srunBase64.EncodeToString(xEncode(tt.str, tt.key)); got != tt.want {
+				t.Errorf("xEncode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSrunLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		ip       string
+		acID     string
+		info     string
+		hmd5     string
+		checksum string
+	}{
+		{
+			name:     "ascii",
+			username: "M202012345",
+			password: "secret",
+			ip:       "10.12.34.56",
+			acID:     "1",
+			info:     "{SRBX1}VF/BW7FpPQhHnJYQzI8znTG0m+4D7zxGSCylL1StrK0D8QkU1a6uh9CKP0/cPAPiT6aP8SBKIx/4FduExOa8ZTZ0oLKxt9xMxGWFSMymvvDTIuhVMmG3fMtCn2eqOXakhelAHv==",
+			hmd5:     "78d6f1db4e83b1180973100c14e1b32f",
+			checksum: "91b4a5777a9322d32303fc3f6b45d9b31dca9939",
+		},
+		{
+			// JSON.stringify不转义<、>和&，非ASCII字符按UTF-16编码单元打包
+			name:     "html and non-ascii characters",
+			username: "u",
+			password: "p<&>\"密码",
+			ip:       "10.0.0.2",
+			acID:     "12",
+			info:     "{SRBX1}EdnCNF0ctUoQJqUUA2d9QISV8A+gKDwPb6ZHByHHH4ynJMH03Z2EnmKFNE5jG381wMyZEbAAgk/Jw54ZVxOeKn7paPvwmsIswUdxSwEi5SBOXvrlzZpPqNLQKwv=",
+			hmd5:     "e84728eb96e03bafda1c1d3fcf88132e",
+			checksum: "72aa802dda7903384ec6d0e50fcdecabf6a1eeea",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := srunInfo(tt.username, tt.password, tt.ip, tt.acID, testSrunToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info != tt.info {
+				t.Errorf("srunInfo() = %s, want %s", info, tt.info)
+			}
+			hmd5 := srunHmacMD5(tt.password, testSrunToken)
+			if hmd5 != tt.hmd5 {
+				t.Errorf("srunHmacMD5() = %s, want %s", hmd5, tt.hmd5)
+			}
+			if got := srunChecksum(testSrunToken, tt.username, hmd5, tt.acID, tt.ip, info); got != tt.checksum {
+				t.Errorf("srunChecksum() = %s, want %s", got, tt.checksum)
+			}
+		})
+	}
+}